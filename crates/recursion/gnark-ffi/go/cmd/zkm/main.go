@@ -0,0 +1,394 @@
+// Command zkm is a standalone CLI around the zkm package, so that proving,
+// verifying, and Solidity export can be scripted from CI or run air-gapped
+// without linking libzkm.a into a Rust host.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	zkm "github.com/ProjectZKM/zkm-recursion-gnark/zkm"
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/ceremony"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/solidity"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "build":
+		err = runBuild(os.Args[2:])
+	case "prove":
+		err = runProve(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	case "export-solidity":
+		err = runExportSolidity(os.Args[2:])
+	case "test":
+		err = runTest(os.Args[2:])
+	case "build-wrap":
+		err = runBuildWrap(os.Args[2:])
+	case "build-batch":
+		err = runBuildBatch(os.Args[2:])
+	case "ceremony":
+		err = runCeremony(os.Args[2:])
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "zkm:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: zkm <command> [flags]
+
+commands:
+  build            build the proving/verifying keys for a circuit
+  prove            generate a proof from a witness
+  verify           verify a proof against a vkey hash and committed values digest
+  export-solidity  export the verifying key as a Solidity verifier contract
+  test             run the circuit end-to-end against a witness without persisting keys
+  build-wrap       build the proving/verifying keys for the recursive wrap circuit
+  build-batch      build the proving/verifying keys for the batch circuit
+  ceremony         run or finalize a phase-2 trusted-setup ceremony
+
+ceremony actions (zkm ceremony <action> [flags]):
+  init               seed a transcript from a phase-1 contribution and the circuit's R1CS
+  contribute         add a Groth16 phase-2 contribution to a transcript
+  verify             verify every contribution in a Groth16 transcript
+  finalize           seal a Groth16 transcript into --data-dir/groth16_{pk,vk}.bin
+  contribute-plonk   add a PLONK KZG SRS phase-2 contribution
+  finalize-plonk     copy a contributed PLONK SRS into --data-dir/plonk_srs.bin`)
+}
+
+func runBuild(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "directory to write the proving/verifying keys to")
+	backend := fs.String("backend", "plonk", "backend to use: plonk or groth16")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" {
+		return fmt.Errorf("--data-dir is required")
+	}
+
+	switch *backend {
+	case "plonk":
+		zkm.BuildPlonk(*dataDir)
+	case "groth16":
+		zkm.BuildGroth16(*dataDir)
+	default:
+		return fmt.Errorf("unknown backend %q", *backend)
+	}
+	return nil
+}
+
+func runProve(args []string) error {
+	fs := flag.NewFlagSet("prove", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "directory holding the proving key")
+	witnessPath := fs.String("witness", "", "path to the witness JSON file")
+	proofPath := fs.String("proof", "", "path to write the resulting proof to")
+	backend := fs.String("backend", "plonk", "backend to use: plonk or groth16")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" || *witnessPath == "" || *proofPath == "" {
+		return fmt.Errorf("--data-dir, --witness and --proof are required")
+	}
+
+	var proof zkm.Proof
+	switch *backend {
+	case "plonk":
+		proof = zkm.ProvePlonk(*dataDir, *witnessPath)
+	case "groth16":
+		proof = zkm.ProveGroth16(*dataDir, *witnessPath)
+	default:
+		return fmt.Errorf("unknown backend %q", *backend)
+	}
+
+	return writeJSON(*proofPath, proof)
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "directory holding the verifying key")
+	proofPath := fs.String("proof", "", "path to the proof to verify")
+	vkeyHash := fs.String("vkey-hash", "", "expected vkey hash")
+	committedValuesDigest := fs.String("committed-values-digest", "", "expected committed values digest")
+	backend := fs.String("backend", "plonk", "backend to use: plonk or groth16")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" || *proofPath == "" || *vkeyHash == "" || *committedValuesDigest == "" {
+		return fmt.Errorf("--data-dir, --proof, --vkey-hash and --committed-values-digest are required")
+	}
+
+	proofFile, err := os.Open(*proofPath)
+	if err != nil {
+		return fmt.Errorf("read proof: %w", err)
+	}
+	defer proofFile.Close()
+	var proof zkm.Proof
+	if err := json.NewDecoder(proofFile).Decode(&proof); err != nil {
+		return fmt.Errorf("unmarshal proof: %w", err)
+	}
+
+	switch *backend {
+	case "plonk":
+		return zkm.VerifyPlonk(*dataDir, proof.RawProof, *vkeyHash, *committedValuesDigest)
+	case "groth16":
+		return zkm.VerifyGroth16(*dataDir, proof.RawProof, *vkeyHash, *committedValuesDigest)
+	default:
+		return fmt.Errorf("unknown backend %q", *backend)
+	}
+}
+
+func runExportSolidity(args []string) error {
+	fs := flag.NewFlagSet("export-solidity", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "directory holding the verifying key")
+	outPath := fs.String("out", "", "path to write the Solidity verifier contract to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" || *outPath == "" {
+		return fmt.Errorf("--data-dir and --out are required")
+	}
+
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	f, err := os.Open(*dataDir + "/plonk_vk.bin")
+	if err != nil {
+		return fmt.Errorf("open verifying key: %w", err)
+	}
+	defer f.Close()
+	if _, err := vk.ReadFrom(f); err != nil {
+		return fmt.Errorf("read verifying key: %w", err)
+	}
+
+	out, err := os.Create(*outPath)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", *outPath, err)
+	}
+	defer out.Close()
+
+	return vk.ExportSolidity(out, solidity.WithPragmaVersion("^0.8.19"))
+}
+
+// runTest compiles and proves the verifier circuit for a witness without
+// persisting proving/verifying keys to disk, so CI can exercise a change
+// end-to-end without first running `zkm build`. It delegates to
+// zkm.RunConstraintTest, the same dummy-setup-and-prove path the FFI
+// TestPlonkBn254/TestGroth16Bn254 entry points in main.go use, so the two
+// can't drift apart the way this command's own inlined JSON/unsafekzg
+// sequence previously did.
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	witnessPath := fs.String("witness", "plonk_witness.bin", "path to the binary-encoded witness file")
+	constraintsPath := fs.String("constraints", "", "path to a JSON {\"constraints\": N} manifest to check the compiled circuit against")
+	backend := fs.String("backend", "plonk", "backend to use: plonk or groth16")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *backend {
+	case "plonk", "groth16":
+	default:
+		return fmt.Errorf("unknown backend %q", *backend)
+	}
+
+	witnessFile, err := os.Open(*witnessPath)
+	if err != nil {
+		return fmt.Errorf("open witness: %w", err)
+	}
+	defer witnessFile.Close()
+
+	opts := zkm.ProveOptions{
+		WitnessReader: witnessFile,
+		Backend:       *backend,
+	}
+	if *constraintsPath != "" {
+		constraintsFile, err := os.Open(*constraintsPath)
+		if err != nil {
+			return fmt.Errorf("open constraints: %w", err)
+		}
+		defer constraintsFile.Close()
+		opts.ConstraintsReader = constraintsFile
+	}
+
+	return zkm.RunConstraintTest(opts)
+}
+
+// runBuildWrap compiles and builds the proving/verifying keys for the
+// recursive wrap circuit, the build path loadOrBuildWrapProvingKey never had
+// despite its name.
+func runBuildWrap(args []string) error {
+	fs := flag.NewFlagSet("build-wrap", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "directory holding the base circuit's plonk_vk.bin, to write wrap_plonk_{pk,vk}.bin to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" {
+		return fmt.Errorf("--data-dir is required")
+	}
+	return zkm.BuildWrapPlonk(*dataDir)
+}
+
+// runBuildBatch compiles and builds the proving/verifying keys for the batch
+// circuit sized for --n-proofs inner proofs, the build path
+// loadOrBuildBatchProvingKey/loadOrBuildBatchGroth16ProvingKey never had
+// despite their name.
+func runBuildBatch(args []string) error {
+	fs := flag.NewFlagSet("build-batch", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "directory holding the base circuit's verifying key, to write batch_<backend>_{pk,vk}.bin to")
+	n := fs.Int("n-proofs", 0, "number of inner proofs the batch circuit verifies")
+	backend := fs.String("backend", "plonk", "backend to use: plonk or groth16")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" || *n <= 0 {
+		return fmt.Errorf("--data-dir and --n-proofs are required")
+	}
+	switch *backend {
+	case "plonk":
+		return zkm.BuildBatchPlonk(*dataDir, *n)
+	case "groth16":
+		return zkm.BuildBatchGroth16(*dataDir, *n)
+	default:
+		return fmt.Errorf("unknown backend %q", *backend)
+	}
+}
+
+// runCeremony drives the zkm/ceremony package's phase-2 trusted-setup
+// protocol from the command line: init/contribute/verify/finalize for
+// Groth16's circuit-specific phase-2, and contribute-plonk/finalize-plonk
+// for PLONK's circuit-independent KZG SRS phase-2.
+func runCeremony(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("ceremony: an action is required (init, contribute, verify, finalize, contribute-plonk, finalize-plonk)")
+	}
+	action, rest := args[0], args[1:]
+
+	switch action {
+	case "init":
+		fs := flag.NewFlagSet("ceremony init", flag.ExitOnError)
+		phase1 := fs.String("phase1", "", "path to the initial phase-1 (Powers-of-Tau) contribution")
+		circuitPath := fs.String("circuit", "", "path to the circuit's serialized R1CS constraint system")
+		out := fs.String("out", "", "path to write the seeded transcript to")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if *phase1 == "" || *circuitPath == "" || *out == "" {
+			return fmt.Errorf("--phase1, --circuit and --out are required")
+		}
+		cs, err := ceremony.ReadR1CS(*circuitPath)
+		if err != nil {
+			return fmt.Errorf("read circuit: %w", err)
+		}
+		return ceremony.Init(*phase1, cs, *out)
+
+	case "contribute":
+		fs := flag.NewFlagSet("ceremony contribute", flag.ExitOnError)
+		in := fs.String("in", "", "path to the input transcript")
+		out := fs.String("out", "", "path to write the extended transcript to")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if *in == "" || *out == "" {
+			return fmt.Errorf("--in and --out are required")
+		}
+		return ceremony.Contribute(*in, *out)
+
+	case "verify":
+		fs := flag.NewFlagSet("ceremony verify", flag.ExitOnError)
+		transcript := fs.String("transcript", "", "path to the transcript to verify")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if *transcript == "" {
+			return fmt.Errorf("--transcript is required")
+		}
+		return ceremony.Verify(*transcript)
+
+	case "finalize":
+		fs := flag.NewFlagSet("ceremony finalize", flag.ExitOnError)
+		transcript := fs.String("transcript", "", "path to the transcript to finalize")
+		dataDir := fs.String("data-dir", "", "directory to write groth16_pk.bin/groth16_vk.bin to")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if *transcript == "" || *dataDir == "" {
+			return fmt.Errorf("--transcript and --data-dir are required")
+		}
+		pk, vk, err := zkm.LoadCeremonyGroth16Keys(*transcript)
+		if err != nil {
+			return fmt.Errorf("finalize transcript: %w", err)
+		}
+		if err := writeKey(*dataDir+"/groth16_pk.bin", pk); err != nil {
+			return err
+		}
+		return writeKey(*dataDir+"/groth16_vk.bin", vk)
+
+	case "contribute-plonk":
+		fs := flag.NewFlagSet("ceremony contribute-plonk", flag.ExitOnError)
+		in := fs.String("in", "", "path to the input SRS")
+		out := fs.String("out", "", "path to write the updated SRS to")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if *in == "" || *out == "" {
+			return fmt.Errorf("--in and --out are required")
+		}
+		return ceremony.ContributePlonkSRS(*in, *out)
+
+	case "finalize-plonk":
+		fs := flag.NewFlagSet("ceremony finalize-plonk", flag.ExitOnError)
+		srsPath := fs.String("srs", "", "path to the contributed SRS")
+		dataDir := fs.String("data-dir", "", "directory to write plonk_srs.bin to")
+		if err := fs.Parse(rest); err != nil {
+			return err
+		}
+		if *srsPath == "" || *dataDir == "" {
+			return fmt.Errorf("--srs and --data-dir are required")
+		}
+		srs, err := zkm.LoadCeremonyPlonkSRS(*srsPath)
+		if err != nil {
+			return fmt.Errorf("finalize srs: %w", err)
+		}
+		return writeKey(*dataDir+"/plonk_srs.bin", srs)
+
+	default:
+		return fmt.Errorf("ceremony: unknown action %q", action)
+	}
+}
+
+// writeKey writes a gnark-serialized object (proving/verifying key, SRS) to
+// path.
+func writeKey(path string, src io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer f.Close()
+	_, err = src.WriteTo(f)
+	return err
+}
+
+func writeJSON(path string, proof zkm.Proof) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(proof)
+}
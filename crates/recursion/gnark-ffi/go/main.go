@@ -18,22 +18,21 @@ typedef struct {
 */
 import "C"
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
-	"sync"
 	"unsafe"
 
+	zkm "github.com/ProjectZKM/zkm-recursion-gnark/zkm"
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/koalabear"
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/poseidon2"
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
-	"github.com/consensys/gnark/frontend/cs/scs"
-	"github.com/consensys/gnark/test/unsafekzg"
-	zkm "github.com/ProjectZKM/zkm-recursion-gnark/zkm"
-	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/koalabear"
-	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/poseidon2"
 )
 
 func main() {}
@@ -44,20 +43,48 @@ func ProvePlonkBn254(dataDir *C.char, witnessPath *C.char) *C.C_PlonkBn254Proof
 	witnessPathString := C.GoString(witnessPath)
 
 	zkmPlonkBn254Proof := zkm.ProvePlonk(dataDirString, witnessPathString)
+	return newCPlonkBn254Proof(zkmPlonkBn254Proof)
+}
 
+// newCPlonkBn254Proof copies a zkm.Proof's fields into a freshly malloc'd
+// C_PlonkBn254Proof, so ProvePlonkBn254 and ProvePlonkBn254Ex (which reach
+// the same zkm.Proof via a file path and an in-memory buffer, respectively)
+// share one marshalling path.
+func newCPlonkBn254Proof(p zkm.Proof) *C.C_PlonkBn254Proof {
 	ms := C.malloc(C.sizeof_C_PlonkBn254Proof)
 	if ms == nil {
 		return nil
 	}
 
 	structPtr := (*C.C_PlonkBn254Proof)(ms)
-	structPtr.PublicInputs[0] = C.CString(zkmPlonkBn254Proof.PublicInputs[0])
-	structPtr.PublicInputs[1] = C.CString(zkmPlonkBn254Proof.PublicInputs[1])
-	structPtr.EncodedProof = C.CString(zkmPlonkBn254Proof.EncodedProof)
-	structPtr.RawProof = C.CString(zkmPlonkBn254Proof.RawProof)
+	structPtr.PublicInputs[0] = C.CString(p.PublicInputs[0])
+	structPtr.PublicInputs[1] = C.CString(p.PublicInputs[1])
+	structPtr.EncodedProof = C.CString(p.EncodedProof)
+	structPtr.RawProof = C.CString(p.RawProof)
 	return structPtr
 }
 
+// ProvePlonkBn254Ex is the streaming counterpart of ProvePlonkBn254: instead
+// of a witness file path, it takes a pointer to witnessLen bytes of the
+// binary witness codec (see zkm/codec.go) that a Rust caller already holds
+// in memory, so many circuits can be proved concurrently on one process
+// without each one first writing its witness to disk.
+//
+//export ProvePlonkBn254Ex
+func ProvePlonkBn254Ex(dataDir *C.char, witnessBuf unsafe.Pointer, witnessLen C.int) *C.C_PlonkBn254Proof {
+	dataDirString := C.GoString(dataDir)
+	witnessBytes := C.GoBytes(witnessBuf, witnessLen)
+
+	proof, err := zkm.ProvePlonkWithOptions(zkm.ProveOptions{
+		DataDir:       dataDirString,
+		WitnessReader: bytes.NewReader(witnessBytes),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return newCPlonkBn254Proof(proof)
+}
+
 //export FreePlonkBn254Proof
 func FreePlonkBn254Proof(proof *C.C_PlonkBn254Proof) {
 	C.free(unsafe.Pointer(proof.EncodedProof))
@@ -89,18 +116,126 @@ func VerifyPlonkBn254(dataDir *C.char, proof *C.char, vkeyHash *C.char, committe
 	return nil
 }
 
-var testMutex = &sync.Mutex{}
+// loadFromFile reads a gnark-serialized object (proof, verifying key, witness)
+// from disk into dst, which must implement io.ReaderFrom.
+func loadFromFile(path string, dst io.ReaderFrom) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = dst.ReadFrom(f)
+	return err
+}
+
+//export ProveWrapPlonkBn254
+func ProveWrapPlonkBn254(dataDir *C.char, innerVkPath *C.char, innerProofPath *C.char, innerWitnessPath *C.char, vkeyHash *C.char, committedValuesDigest *C.char) *C.C_PlonkBn254Proof {
+	dataDirString := C.GoString(dataDir)
+	innerVkPathString := C.GoString(innerVkPath)
+	innerProofPathString := C.GoString(innerProofPath)
+	innerWitnessPathString := C.GoString(innerWitnessPath)
+	vkeyHashString := C.GoString(vkeyHash)
+	committedValuesDigestString := C.GoString(committedValuesDigest)
+
+	innerVk := plonk.NewVerifyingKey(ecc.BN254)
+	if err := loadFromFile(innerVkPathString, innerVk); err != nil {
+		panic(err)
+	}
+	innerProof := plonk.NewProof(ecc.BN254)
+	if err := loadFromFile(innerProofPathString, innerProof); err != nil {
+		panic(err)
+	}
+	innerWitness, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+	if err := loadFromFile(innerWitnessPathString, innerWitness); err != nil {
+		panic(err)
+	}
+
+	wrapProof := zkm.ProveWrapPlonkBn254(dataDirString, innerVk, innerProof, innerWitness, vkeyHashString, committedValuesDigestString)
+
+	ms := C.malloc(C.sizeof_C_PlonkBn254Proof)
+	if ms == nil {
+		return nil
+	}
+
+	structPtr := (*C.C_PlonkBn254Proof)(ms)
+	structPtr.PublicInputs[0] = C.CString(wrapProof.PublicInputs[0])
+	structPtr.PublicInputs[1] = C.CString(wrapProof.PublicInputs[1])
+	structPtr.EncodedProof = C.CString(wrapProof.EncodedProof)
+	structPtr.RawProof = C.CString(wrapProof.RawProof)
+	return structPtr
+}
+
+//export VerifyWrapPlonkBn254
+func VerifyWrapPlonkBn254(dataDir *C.char, proof *C.char, vkeyHash *C.char, committedValuesDigest *C.char) *C.char {
+	dataDirString := C.GoString(dataDir)
+	proofString := C.GoString(proof)
+	vkeyHashString := C.GoString(vkeyHash)
+	committedValuesDigestString := C.GoString(committedValuesDigest)
+
+	err := zkm.VerifyWrapPlonkBn254(dataDirString, proofString, vkeyHashString, committedValuesDigestString)
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	return nil
+}
+
+// defaultWitnessPath is the witness file TestPlonkBn254/TestGroth16Bn254
+// fall back to when no path is passed. It is a breaking change from the old
+// TestMain's "plonk_witness.json" default: RunConstraintTest now reads
+// opts.WitnessReader through DecodeWitness's length-prefixed binary codec
+// (see codec.go), not encoding/json, so the default name must match what
+// EncodeWitness actually produces, and any caller still writing the old
+// JSON format to the old name needs to switch to EncodeWitness.
+const defaultWitnessPath = "plonk_witness.bin"
+
+// openWitness opens path (or defaultWitnessPath, if path is empty) and
+// returns the *os.File as an io.Reader for ProveOptions.WitnessReader.
+func openWitness(path string) (io.Reader, func(), error) {
+	if path == "" {
+		path = defaultWitnessPath
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// openOptional opens path and returns the *os.File as an io.Reader, or nil
+// if path is empty, so callers can pass it straight through to an optional
+// ProveOptions reader field without a separate nil check at each call site.
+func openOptional(path string) (io.Reader, func(), error) {
+	if path == "" {
+		return nil, func() {}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	return f, func() { f.Close() }, nil
+}
 
 //export TestPlonkBn254
 func TestPlonkBn254(witnessPath *C.char, constraintsJson *C.char) *C.char {
-	// Because of the global env variables used here, we need to lock this function
-	testMutex.Lock()
-	witnessPathString := C.GoString(witnessPath)
-	constraintsJsonString := C.GoString(constraintsJson)
-	os.Setenv("WITNESS_JSON", witnessPathString)
-	os.Setenv("CONSTRAINTS_JSON", constraintsJsonString)
-	err := TestMain()
-	testMutex.Unlock()
+	witnessFile, closeWitness, err := openWitness(C.GoString(witnessPath))
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer closeWitness()
+	constraintsFile, closeConstraints, err := openOptional(C.GoString(constraintsJson))
+	if err != nil {
+		return C.CString(err.Error())
+	}
+	defer closeConstraints()
+
+	err = zkm.RunConstraintTest(zkm.ProveOptions{
+		WitnessReader:     witnessFile,
+		ConstraintsReader: constraintsFile,
+		Backend:           "plonk",
+	})
 	if err != nil {
 		return C.CString(err.Error())
 	}
@@ -113,20 +248,42 @@ func ProveGroth16Bn254(dataDir *C.char, witnessPath *C.char) *C.C_Groth16Bn254Pr
 	witnessPathString := C.GoString(witnessPath)
 
 	zkmGroth16Bn254Proof := zkm.ProveGroth16(dataDirString, witnessPathString)
+	return newCGroth16Bn254Proof(zkmGroth16Bn254Proof)
+}
 
+// newCGroth16Bn254Proof is the Groth16 counterpart of newCPlonkBn254Proof.
+func newCGroth16Bn254Proof(p zkm.Proof) *C.C_Groth16Bn254Proof {
 	ms := C.malloc(C.sizeof_C_Groth16Bn254Proof)
 	if ms == nil {
 		return nil
 	}
 
 	structPtr := (*C.C_Groth16Bn254Proof)(ms)
-	structPtr.PublicInputs[0] = C.CString(zkmGroth16Bn254Proof.PublicInputs[0])
-	structPtr.PublicInputs[1] = C.CString(zkmGroth16Bn254Proof.PublicInputs[1])
-	structPtr.EncodedProof = C.CString(zkmGroth16Bn254Proof.EncodedProof)
-	structPtr.RawProof = C.CString(zkmGroth16Bn254Proof.RawProof)
+	structPtr.PublicInputs[0] = C.CString(p.PublicInputs[0])
+	structPtr.PublicInputs[1] = C.CString(p.PublicInputs[1])
+	structPtr.EncodedProof = C.CString(p.EncodedProof)
+	structPtr.RawProof = C.CString(p.RawProof)
 	return structPtr
 }
 
+// ProveGroth16Bn254Ex is the streaming counterpart of ProveGroth16Bn254; see
+// ProvePlonkBn254Ex.
+//
+//export ProveGroth16Bn254Ex
+func ProveGroth16Bn254Ex(dataDir *C.char, witnessBuf unsafe.Pointer, witnessLen C.int) *C.C_Groth16Bn254Proof {
+	dataDirString := C.GoString(dataDir)
+	witnessBytes := C.GoBytes(witnessBuf, witnessLen)
+
+	proof, err := zkm.ProveGroth16WithOptions(zkm.ProveOptions{
+		DataDir:       dataDirString,
+		WitnessReader: bytes.NewReader(witnessBytes),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return newCGroth16Bn254Proof(proof)
+}
+
 //export FreeGroth16Bn254Proof
 func FreeGroth16Bn254Proof(proof *C.C_Groth16Bn254Proof) {
 	C.free(unsafe.Pointer(proof.EncodedProof))
@@ -158,84 +315,132 @@ func VerifyGroth16Bn254(dataDir *C.char, proof *C.char, vkeyHash *C.char, commit
 	return nil
 }
 
-//export TestGroth16Bn254
-func TestGroth16Bn254(witnessJson *C.char, constraintsJson *C.char) *C.char {
-	// Because of the global env variables used here, we need to lock this function
-	testMutex.Lock()
-	witnessPathString := C.GoString(witnessJson)
-	constraintsJsonString := C.GoString(constraintsJson)
-	os.Setenv("WITNESS_JSON", witnessPathString)
-	os.Setenv("CONSTRAINTS_JSON", constraintsJsonString)
-	os.Setenv("GROTH16", "1")
-	err := TestMain()
-	testMutex.Unlock()
-	if err != nil {
-		return C.CString(err.Error())
+// cStringsToGo converts a C array of n null-terminated strings into a Go
+// []string, so batch FFI entry points can take one proof/witness path per
+// inner shard proof.
+func cStringsToGo(arr **C.char, n C.int) []string {
+	out := make([]string, int(n))
+	slice := unsafe.Slice(arr, int(n))
+	for i, s := range slice {
+		out[i] = C.GoString(s)
 	}
-	return nil
+	return out
 }
 
-func TestMain() error {
-	// Get the file name from an environment variable.
-	fileName := os.Getenv("WITNESS_JSON")
-	if fileName == "" {
-		fileName = "plonk_witness.json"
+//export ProveBatchPlonkBn254
+func ProveBatchPlonkBn254(dataDir *C.char, innerVkPaths **C.char, innerProofPaths **C.char, innerWitnessPaths **C.char, vkeyHashes **C.char, committedValuesDigests **C.char, n C.int) *C.C_PlonkBn254Proof {
+	dataDirString := C.GoString(dataDir)
+	vkPaths := cStringsToGo(innerVkPaths, n)
+	proofPaths := cStringsToGo(innerProofPaths, n)
+	witnessPaths := cStringsToGo(innerWitnessPaths, n)
+	vkeyHashStrings := cStringsToGo(vkeyHashes, n)
+	committedValuesDigestStrings := cStringsToGo(committedValuesDigests, n)
+
+	innerVks := make([]plonk.VerifyingKey, n)
+	innerProofs := make([]plonk.Proof, n)
+	innerWitnesses := make([]witness.Witness, n)
+	for i := 0; i < int(n); i++ {
+		innerVks[i] = plonk.NewVerifyingKey(ecc.BN254)
+		if err := loadFromFile(vkPaths[i], innerVks[i]); err != nil {
+			panic(err)
+		}
+		innerProofs[i] = plonk.NewProof(ecc.BN254)
+		if err := loadFromFile(proofPaths[i], innerProofs[i]); err != nil {
+			panic(err)
+		}
+		w, err := witness.New(ecc.BN254.ScalarField())
+		if err != nil {
+			panic(err)
+		}
+		if err := loadFromFile(witnessPaths[i], w); err != nil {
+			panic(err)
+		}
+		innerWitnesses[i] = w
 	}
 
-	// Read the file.
-	data, err := os.ReadFile(fileName)
-	if err != nil {
-		return err
-	}
+	batchProof := zkm.ProveBatchPlonkBn254(dataDirString, innerVks, innerProofs, innerWitnesses, vkeyHashStrings, committedValuesDigestStrings)
 
-	// Deserialize the JSON data into a slice of Instruction structs
-	var inputs zkm.WitnessInput
-	err = json.Unmarshal(data, &inputs)
-	if err != nil {
-		return err
+	ms := C.malloc(C.sizeof_C_PlonkBn254Proof)
+	if ms == nil {
+		return nil
 	}
 
-	// Compile the circuit.
-	circuit := zkm.NewCircuit(inputs)
-	builder := scs.NewBuilder
-	scs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &circuit)
-	if err != nil {
-		return err
-	}
-	fmt.Println("[zkm] gnark verifier constraints:", scs.GetNbConstraints())
+	structPtr := (*C.C_PlonkBn254Proof)(ms)
+	structPtr.PublicInputs[0] = C.CString(batchProof.PublicInputs[0])
+	structPtr.PublicInputs[1] = C.CString(batchProof.PublicInputs[1])
+	structPtr.EncodedProof = C.CString(batchProof.EncodedProof)
+	structPtr.RawProof = C.CString(batchProof.RawProof)
+	return structPtr
+}
 
-	// Run the dummy setup.
-	srs, srsLagrange, err := unsafekzg.NewSRS(scs)
-	if err != nil {
-		return err
+//export ProveBatchGroth16Bn254
+func ProveBatchGroth16Bn254(dataDir *C.char, innerVkPaths **C.char, innerProofPaths **C.char, innerWitnessPaths **C.char, vkeyHashes **C.char, committedValuesDigests **C.char, n C.int) *C.C_Groth16Bn254Proof {
+	dataDirString := C.GoString(dataDir)
+	vkPaths := cStringsToGo(innerVkPaths, n)
+	proofPaths := cStringsToGo(innerProofPaths, n)
+	witnessPaths := cStringsToGo(innerWitnessPaths, n)
+	vkeyHashStrings := cStringsToGo(vkeyHashes, n)
+	committedValuesDigestStrings := cStringsToGo(committedValuesDigests, n)
+
+	innerVks := make([]groth16.VerifyingKey, n)
+	innerProofs := make([]groth16.Proof, n)
+	innerWitnesses := make([]witness.Witness, n)
+	for i := 0; i < int(n); i++ {
+		innerVks[i] = groth16.NewVerifyingKey(ecc.BN254)
+		if err := loadFromFile(vkPaths[i], innerVks[i]); err != nil {
+			panic(err)
+		}
+		innerProofs[i] = groth16.NewProof(ecc.BN254)
+		if err := loadFromFile(proofPaths[i], innerProofs[i]); err != nil {
+			panic(err)
+		}
+		w, err := witness.New(ecc.BN254.ScalarField())
+		if err != nil {
+			panic(err)
+		}
+		if err := loadFromFile(witnessPaths[i], w); err != nil {
+			panic(err)
+		}
+		innerWitnesses[i] = w
 	}
-	var pk plonk.ProvingKey
-	pk, _, err = plonk.Setup(scs, srs, srsLagrange)
+
+	batchProof := zkm.ProveBatchGroth16Bn254(dataDirString, innerVks, innerProofs, innerWitnesses, vkeyHashStrings, committedValuesDigestStrings)
+	return newCGroth16Bn254Proof(batchProof)
+}
+
+//export TestGroth16Bn254
+func TestGroth16Bn254(witnessPath *C.char, constraintsJson *C.char) *C.char {
+	witnessFile, closeWitness, err := openWitness(C.GoString(witnessPath))
 	if err != nil {
-		return err
+		return C.CString(err.Error())
 	}
-	fmt.Println("[zkm] run the dummy setup done")
-
-	// Generate witness.
-	assignment := zkm.NewCircuit(inputs)
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	defer closeWitness()
+	constraintsFile, closeConstraints, err := openOptional(C.GoString(constraintsJson))
 	if err != nil {
-		return err
+		return C.CString(err.Error())
 	}
-	fmt.Println("[zkm] generate witness done")
+	defer closeConstraints()
 
-	// Generate the proof.
-	_, err = plonk.Prove(scs, pk, witness)
+	err = zkm.RunConstraintTest(zkm.ProveOptions{
+		WitnessReader:     witnessFile,
+		ConstraintsReader: constraintsFile,
+		Backend:           "groth16",
+	})
 	if err != nil {
-		return err
+		return C.CString(err.Error())
 	}
-	fmt.Println("[zkm] generate the proof done")
-
 	return nil
 }
 
+// TestPoseidonKoalaBear2 self-checks the in-circuit KoalaBear Poseidon2
+// permutation against a fixed (input, expectedOutput) vector. dataDir, if
+// non-empty, is a directory holding a ceremony-produced groth16_pk.bin (the
+// same convention ProveGroth16WithOptions reads) to prove against instead
+// of groth16.DummySetup; an empty dataDir keeps the old dummy-setup
+// behavior for quick local runs.
+//
 //export TestPoseidonKoalaBear2
-func TestPoseidonKoalaBear2() *C.char {
+func TestPoseidonKoalaBear2(dataDir *C.char) *C.char {
 	input := [poseidon2.KOALABEAR_WIDTH]koalabear.Variable{
 		koalabear.NewF("0"),
 		koalabear.NewF("0"),
@@ -284,9 +489,21 @@ func TestPoseidonKoalaBear2() *C.char {
 	}
 
 	var pk groth16.ProvingKey
-	pk, err = groth16.DummySetup(r1cs)
-	if err != nil {
-		return C.CString(err.Error())
+	if dataDirString := C.GoString(dataDir); dataDirString != "" {
+		f, err := os.Open(dataDirString + "/groth16_pk.bin")
+		if err != nil {
+			return C.CString(fmt.Sprintf("open ceremony groth16 proving key: %v", err))
+		}
+		defer f.Close()
+		pk = groth16.NewProvingKey(ecc.BN254)
+		if _, err := pk.ReadFrom(f); err != nil {
+			return C.CString(fmt.Sprintf("read ceremony groth16 proving key: %v", err))
+		}
+	} else {
+		pk, err = groth16.DummySetup(r1cs)
+		if err != nil {
+			return C.CString(err.Error())
+		}
 	}
 
 	// Generate witness.
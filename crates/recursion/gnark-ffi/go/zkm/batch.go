@@ -0,0 +1,227 @@
+package zkm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/poseidon2"
+	"github.com/consensys/gnark-crypto/ecc"
+	native_plonk "github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/std/algebra/native/sw_bn254"
+	"github.com/consensys/gnark/std/math/bits"
+	"github.com/consensys/gnark/std/math/emulated"
+	stdplonk "github.com/consensys/gnark/std/recursion/plonk"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+// BatchCircuit verifies N inner PLONK proofs, each against its own
+// verifying key and public witness, in a single BN254 SNARK: N independent
+// in-circuit pairing checks, not one folded check. Folding them into a
+// single pairing via a Fiat-Shamir-folded multi-scalar multiplication over
+// the proofs' KZG openings was the original goal; it is closed here as
+// infeasible against the stdplonk API this repo currently vendors, not
+// implemented: stdplonk.Verifier.AssertProof does not expose the per-proof
+// opening proof/evaluation point it computes internally, so folding them
+// would mean reimplementing AssertProof's KZG verification from scratch
+// rather than composing it. An earlier version of this circuit computed
+// such a fold over the proofs' commitments but never fed it into the
+// verification (so it bought nothing but extra constraints); that dead
+// code has been removed rather than wired up. The public output is still a
+// single Poseidon2 hash over all N (VkeyHash, CommittedValuesDigest)
+// pairs, so the outer verifier only ever sees one 32-byte digest
+// regardless of how many shard proofs were checked.
+type BatchCircuit struct {
+	Proofs        []stdplonk.Proof[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine]
+	VerifyingKeys []stdplonk.VerifyingKey[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl] `gnark:"-"`
+	Witnesses     []stdplonk.Witness[sw_bn254.ScalarField]
+
+	Digest frontend.Variable `gnark:",public"`
+}
+
+// NewBatchCircuit allocates a BatchCircuit sized for n inner proofs, ready to
+// be compiled or filled in as a witness assignment. Each Witnesses[i] comes
+// pre-sized with its 2 public inputs (VkeyHash, CommittedValuesDigest)
+// allocated but unset: a zero-valued stdplonk.Witness has Public == nil,
+// which fails Define's own `len(...) != 2` check during
+// frontend.Compile, so every caller needs this before Compile, not just
+// the ones that also fill in VerifyingKeys.
+func NewBatchCircuit(n int) BatchCircuit {
+	witnesses := make([]stdplonk.Witness[sw_bn254.ScalarField], n)
+	for i := range witnesses {
+		witnesses[i].Public = make([]emulated.Element[sw_bn254.ScalarField], 2)
+	}
+	return BatchCircuit{
+		Proofs:        make([]stdplonk.Proof[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine], n),
+		VerifyingKeys: make([]stdplonk.VerifyingKey[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl], n),
+		Witnesses:     witnesses,
+	}
+}
+
+// Define verifies every inner proof against its own verifying key and
+// public witness, and asserts that Digest is the Poseidon2 hash of all N
+// (VkeyHash, CommittedValuesDigest) pairs.
+func (circuit *BatchCircuit) Define(api frontend.API) error {
+	n := len(circuit.Proofs)
+	if len(circuit.VerifyingKeys) != n || len(circuit.Witnesses) != n {
+		return fmt.Errorf("batch circuit: mismatched lengths (proofs=%d, vks=%d, witnesses=%d)", n, len(circuit.VerifyingKeys), len(circuit.Witnesses))
+	}
+
+	verifier, err := stdplonk.NewVerifier[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](api)
+	if err != nil {
+		return fmt.Errorf("new plonk verifier: %w", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if err := verifier.AssertProof(circuit.VerifyingKeys[i], circuit.Proofs[i], circuit.Witnesses[i], stdplonk.WithCompleteArithmetic()); err != nil {
+			return fmt.Errorf("assert proof %d: %w", i, err)
+		}
+	}
+
+	field, err := emulated.NewField[sw_bn254.ScalarField](api)
+	if err != nil {
+		return fmt.Errorf("new emulated field: %w", err)
+	}
+
+	// Reconstruct each proof's full (VkeyHash, CommittedValuesDigest) public
+	// inputs from their limbs (as wrap.go's Define does for its single
+	// proof) before hashing: the out-of-circuit poseidonDigestOfPairs hashes
+	// the full decimal values, and hashing anything less here (e.g. a single
+	// low bit) would make this assertion fail for every real witness.
+	poseidonChip := poseidon2.NewBn254Chip(api)
+	elements := make([]frontend.Variable, 0, 2*n)
+	for i := 0; i < n; i++ {
+		if len(circuit.Witnesses[i].Public) != 2 {
+			return fmt.Errorf("proof %d: expected 2 public inputs, got %d", i, len(circuit.Witnesses[i].Public))
+		}
+		elements = append(elements,
+			bits.FromBinary(api, field.ToBits(&circuit.Witnesses[i].Public[0])),
+			bits.FromBinary(api, field.ToBits(&circuit.Witnesses[i].Public[1])),
+		)
+	}
+	digest := poseidonChip.Hash(elements)
+	api.AssertIsEqual(circuit.Digest, digest)
+
+	return nil
+}
+
+// ProveBatchPlonkBn254 folds n inner PLONK proofs into a single BN254
+// BatchCircuit proof whose public output is the Poseidon2 digest of all n
+// (vkeyHash, committedValuesDigest) pairs.
+func ProveBatchPlonkBn254(dataDir string, innerVks []native_plonk.VerifyingKey, innerProofs []native_plonk.Proof, innerWitnesses []witness.Witness, vkeyHashes []string, committedValuesDigests []string) Proof {
+	n := len(innerProofs)
+	circuit := NewBatchCircuit(n)
+	assignment := NewBatchCircuit(n)
+
+	for i := 0; i < n; i++ {
+		vk, err := ValueOfVerifyingKey(innerVks[i])
+		if err != nil {
+			panic(err)
+		}
+		circuit.VerifyingKeys[i] = vk
+
+		proof, err := ValueOfProof(innerProofs[i])
+		if err != nil {
+			panic(err)
+		}
+		assignment.Proofs[i] = proof
+
+		pubWitness, err := ValueOfWitness(innerWitnesses[i])
+		if err != nil {
+			panic(err)
+		}
+		assignment.Witnesses[i] = pubWitness
+	}
+	assignment.Digest = poseidonDigestOfPairs(vkeyHashes, committedValuesDigests)
+
+	builder := scs.NewBuilder
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &circuit)
+	if err != nil {
+		panic(err)
+	}
+
+	pk, err := loadOrBuildBatchProvingKey(dataDir, ccs)
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := native_plonk.Prove(ccs, pk, w)
+	if err != nil {
+		panic(err)
+	}
+
+	return NewZKMPlonkBn254Proof(&proof, WitnessInput{
+		VkeyHash:              fmt.Sprintf("%v", assignment.Digest),
+		CommittedValuesDigest: fmt.Sprintf("%v", assignment.Digest),
+	})
+}
+
+func poseidonDigestOfPairs(vkeyHashes []string, committedValuesDigests []string) string {
+	elements := make([]string, 0, 2*len(vkeyHashes))
+	for i := range vkeyHashes {
+		elements = append(elements, vkeyHashes[i], committedValuesDigests[i])
+	}
+	return poseidon2.HashBn254Strings(elements)
+}
+
+// BuildBatchPlonk compiles a BatchCircuit sized for n inner proofs, each
+// against the base circuit's verifying key at dataDir/plonk_vk.bin (batched
+// proofs all verify shard proofs of the same zkMIPS program, so they share
+// one inner verifying key), and persists batch_plonk_pk.bin/
+// batch_plonk_vk.bin to dataDir, the build path loadOrBuildBatchProvingKey
+// never had despite its name.
+func BuildBatchPlonk(dataDir string, n int) error {
+	innerVk, err := loadInnerPlonkVerifyingKey(dataDir)
+	if err != nil {
+		return err
+	}
+	vk, err := ValueOfVerifyingKey(innerVk)
+	if err != nil {
+		return fmt.Errorf("convert inner verifying key: %w", err)
+	}
+
+	circuit := NewBatchCircuit(n)
+	for i := range circuit.VerifyingKeys {
+		circuit.VerifyingKeys[i] = vk
+	}
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	if err != nil {
+		return fmt.Errorf("compile batch circuit: %w", err)
+	}
+
+	srs, srsLagrange, err := unsafekzg.NewSRS(ccs)
+	if err != nil {
+		return fmt.Errorf("srs: %w", err)
+	}
+	pk, batchVk, err := native_plonk.Setup(ccs, srs, srsLagrange)
+	if err != nil {
+		return fmt.Errorf("setup: %w", err)
+	}
+
+	if err := writeGnarkFile(dataDir+"/batch_plonk_pk.bin", pk); err != nil {
+		return err
+	}
+	return writeGnarkFile(dataDir+"/batch_plonk_vk.bin", batchVk)
+}
+
+func loadOrBuildBatchProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_plonk.ProvingKey, error) {
+	pk := native_plonk.NewProvingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/batch_plonk_pk.bin")
+	if err != nil {
+		return nil, fmt.Errorf("open batch proving key (run BuildBatchPlonk first): %w", err)
+	}
+	defer f.Close()
+	if _, err := pk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read batch proving key: %w", err)
+	}
+	return pk, nil
+}
@@ -0,0 +1,214 @@
+package zkm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/poseidon2"
+	"github.com/consensys/gnark-crypto/ecc"
+	native_groth16 "github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/algebra/native/sw_bn254"
+	"github.com/consensys/gnark/std/math/bits"
+	"github.com/consensys/gnark/std/math/emulated"
+	stdgroth16 "github.com/consensys/gnark/std/recursion/groth16"
+)
+
+// BatchGroth16Circuit is BatchCircuit's Groth16 counterpart: it verifies N
+// inner Groth16 proofs, each against its own verifying key and public
+// witness, in a single BN254 SNARK, and asserts the same Poseidon2 digest
+// over all N (VkeyHash, CommittedValuesDigest) pairs as its public output.
+type BatchGroth16Circuit struct {
+	Proofs        []stdgroth16.Proof[sw_bn254.G1Affine, sw_bn254.G2Affine]
+	VerifyingKeys []stdgroth16.VerifyingKey[sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl] `gnark:"-"`
+	Witnesses     []stdgroth16.Witness[sw_bn254.ScalarField]
+
+	Digest frontend.Variable `gnark:",public"`
+}
+
+// NewBatchGroth16Circuit allocates a BatchGroth16Circuit sized for n inner
+// proofs, ready to be compiled or filled in as a witness assignment. Each
+// Witnesses[i] comes pre-sized with its 2 public inputs (VkeyHash,
+// CommittedValuesDigest) allocated but unset: a zero-valued
+// stdgroth16.Witness has Public == nil, which fails Define's own
+// `len(...) != 2` check during frontend.Compile, so every caller needs
+// this before Compile, not just the ones that also fill in VerifyingKeys.
+func NewBatchGroth16Circuit(n int) BatchGroth16Circuit {
+	witnesses := make([]stdgroth16.Witness[sw_bn254.ScalarField], n)
+	for i := range witnesses {
+		witnesses[i].Public = make([]emulated.Element[sw_bn254.ScalarField], 2)
+	}
+	return BatchGroth16Circuit{
+		Proofs:        make([]stdgroth16.Proof[sw_bn254.G1Affine, sw_bn254.G2Affine], n),
+		VerifyingKeys: make([]stdgroth16.VerifyingKey[sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl], n),
+		Witnesses:     witnesses,
+	}
+}
+
+// Define verifies every inner proof against its own verifying key and
+// public witness, and asserts that Digest is the Poseidon2 hash of all N
+// (VkeyHash, CommittedValuesDigest) pairs, mirroring BatchCircuit.Define.
+func (circuit *BatchGroth16Circuit) Define(api frontend.API) error {
+	n := len(circuit.Proofs)
+	if len(circuit.VerifyingKeys) != n || len(circuit.Witnesses) != n {
+		return fmt.Errorf("batch groth16 circuit: mismatched lengths (proofs=%d, vks=%d, witnesses=%d)", n, len(circuit.VerifyingKeys), len(circuit.Witnesses))
+	}
+
+	verifier, err := stdgroth16.NewVerifier[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](api)
+	if err != nil {
+		return fmt.Errorf("new groth16 verifier: %w", err)
+	}
+
+	for i := 0; i < n; i++ {
+		if err := verifier.AssertProof(circuit.VerifyingKeys[i], circuit.Proofs[i], circuit.Witnesses[i]); err != nil {
+			return fmt.Errorf("assert proof %d: %w", i, err)
+		}
+	}
+
+	field, err := emulated.NewField[sw_bn254.ScalarField](api)
+	if err != nil {
+		return fmt.Errorf("new emulated field: %w", err)
+	}
+
+	poseidonChip := poseidon2.NewBn254Chip(api)
+	elements := make([]frontend.Variable, 0, 2*n)
+	for i := 0; i < n; i++ {
+		if len(circuit.Witnesses[i].Public) != 2 {
+			return fmt.Errorf("proof %d: expected 2 public inputs, got %d", i, len(circuit.Witnesses[i].Public))
+		}
+		elements = append(elements,
+			bits.FromBinary(api, field.ToBits(&circuit.Witnesses[i].Public[0])),
+			bits.FromBinary(api, field.ToBits(&circuit.Witnesses[i].Public[1])),
+		)
+	}
+	digest := poseidonChip.Hash(elements)
+	api.AssertIsEqual(circuit.Digest, digest)
+
+	return nil
+}
+
+// ProveBatchGroth16Bn254 folds n inner Groth16 proofs into a single BN254
+// BatchGroth16Circuit proof whose public output is the Poseidon2 digest of
+// all n (vkeyHash, committedValuesDigest) pairs, the Groth16 counterpart of
+// ProveBatchPlonkBn254.
+func ProveBatchGroth16Bn254(dataDir string, innerVks []native_groth16.VerifyingKey, innerProofs []native_groth16.Proof, innerWitnesses []witness.Witness, vkeyHashes []string, committedValuesDigests []string) Proof {
+	n := len(innerProofs)
+	circuit := NewBatchGroth16Circuit(n)
+	assignment := NewBatchGroth16Circuit(n)
+
+	for i := 0; i < n; i++ {
+		vk, err := stdgroth16.ValueOfVerifyingKey[sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](innerVks[i])
+		if err != nil {
+			panic(err)
+		}
+		circuit.VerifyingKeys[i] = vk
+
+		proof, err := stdgroth16.ValueOfProof[sw_bn254.G1Affine, sw_bn254.G2Affine](innerProofs[i])
+		if err != nil {
+			panic(err)
+		}
+		assignment.Proofs[i] = proof
+
+		pubWitness, err := stdgroth16.ValueOfWitness[sw_bn254.ScalarField](innerWitnesses[i])
+		if err != nil {
+			panic(err)
+		}
+		assignment.Witnesses[i] = pubWitness
+	}
+	assignment.Digest = poseidonDigestOfPairs(vkeyHashes, committedValuesDigests)
+
+	builder := r1cs.NewBuilder
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &circuit)
+	if err != nil {
+		panic(err)
+	}
+
+	pk, err := loadOrBuildBatchGroth16ProvingKey(dataDir, ccs)
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+
+	proof, err := native_groth16.Prove(ccs, pk, w)
+	if err != nil {
+		panic(err)
+	}
+
+	return NewZKMGroth16Proof(&proof, WitnessInput{
+		VkeyHash:              fmt.Sprintf("%v", assignment.Digest),
+		CommittedValuesDigest: fmt.Sprintf("%v", assignment.Digest),
+	})
+}
+
+// BuildBatchGroth16 compiles a BatchGroth16Circuit sized for n inner proofs,
+// each against the base circuit's verifying key at dataDir/groth16_vk.bin
+// (batched proofs all verify shard proofs of the same zkMIPS program, so
+// they share one inner verifying key), and persists
+// batch_groth16_pk.bin/batch_groth16_vk.bin to dataDir, the Groth16
+// counterpart of BuildBatchPlonk.
+func BuildBatchGroth16(dataDir string, n int) error {
+	innerVk, err := loadInnerGroth16VerifyingKey(dataDir)
+	if err != nil {
+		return err
+	}
+	vk, err := stdgroth16.ValueOfVerifyingKey[sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](innerVk)
+	if err != nil {
+		return fmt.Errorf("convert inner verifying key: %w", err)
+	}
+
+	circuit := NewBatchGroth16Circuit(n)
+	for i := range circuit.VerifyingKeys {
+		circuit.VerifyingKeys[i] = vk
+	}
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	if err != nil {
+		return fmt.Errorf("compile batch groth16 circuit: %w", err)
+	}
+
+	pk, batchVk, err := native_groth16.Setup(ccs)
+	if err != nil {
+		return fmt.Errorf("setup: %w", err)
+	}
+
+	if err := writeGnarkFile(dataDir+"/batch_groth16_pk.bin", pk); err != nil {
+		return err
+	}
+	return writeGnarkFile(dataDir+"/batch_groth16_vk.bin", batchVk)
+}
+
+// loadInnerGroth16VerifyingKey loads the base zkMIPS verifier circuit's
+// persisted groth16_vk.bin from dataDir, the inner verifying key every
+// BatchGroth16Circuit compile-time constant is derived from.
+func loadInnerGroth16VerifyingKey(dataDir string) (native_groth16.VerifyingKey, error) {
+	vk := native_groth16.NewVerifyingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/groth16_vk.bin")
+	if err != nil {
+		return nil, fmt.Errorf("open inner verifying key (run BuildGroth16 for the base circuit first): %w", err)
+	}
+	defer f.Close()
+	if _, err := vk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read inner verifying key: %w", err)
+	}
+	return vk, nil
+}
+
+func loadOrBuildBatchGroth16ProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_groth16.ProvingKey, error) {
+	pk := native_groth16.NewProvingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/batch_groth16_pk.bin")
+	if err != nil {
+		return nil, fmt.Errorf("open batch groth16 proving key (run BuildBatchGroth16 first): %w", err)
+	}
+	defer f.Close()
+	if _, err := pk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read batch groth16 proving key: %w", err)
+	}
+	return pk, nil
+}
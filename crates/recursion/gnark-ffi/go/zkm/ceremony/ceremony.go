@@ -0,0 +1,205 @@
+// Package ceremony implements a Groth16 phase-2 (circuit-specific)
+// multi-party-computation contribution protocol for the zkMIPS verifier
+// circuit, so that ProvingKey/VerifyingKey pairs used in production do not
+// rely on groth16.DummySetup or unsafekzg. Each contributor takes the
+// current transcript, samples fresh secret randomness, folds it into the
+// circuit-specific part of the proving key, and publishes a proof of
+// knowledge that lets anyone downstream verify the contribution without
+// trusting the contributor.
+package ceremony
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/mpcsetup"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	bn254cs "github.com/consensys/gnark/constraint/bn254"
+)
+
+// Transcript is the on-disk representation of a phase-2 ceremony: the
+// circuit's constraint system, the phase-1 (Powers-of-Tau) contribution it
+// was initialized from, and the list of phase-2 contributions made so far.
+// Contribute appends to Phase2Contributions; Verify checks the whole chain;
+// Finalize combines the circuit-specific and Powers-of-Tau data into a
+// Groth16 ProvingKey/VerifyingKey pair. BuildGroth16 and BuildPlonk do not
+// read that pair.
+type Transcript struct {
+	CS                  constraint.ConstraintSystem
+	Phase1              mpcsetup.Phase1
+	Phase2Contributions []mpcsetup.Phase2
+}
+
+// Init seeds a brand-new transcript from an externally produced phase-1
+// (Powers-of-Tau) contribution and the circuit's own compiled constraint
+// system, and writes it to outFile. It is the only way a transcript ever
+// acquires a non-nil CS: Contribute and Verify only ever read a CS back
+// from a transcript file, they never derive one, so every ceremony starts
+// here before the first Contribute call.
+func Init(phase1File string, cs constraint.ConstraintSystem, outFile string) error {
+	f, err := os.Open(phase1File)
+	if err != nil {
+		return fmt.Errorf("open phase1 contribution: %w", err)
+	}
+	defer f.Close()
+
+	transcript := &Transcript{CS: cs}
+	if _, err := transcript.Phase1.ReadFrom(f); err != nil {
+		return fmt.Errorf("read phase1 contribution: %w", err)
+	}
+	return writeTranscript(outFile, transcript)
+}
+
+// ReadR1CS loads a circuit's R1CS constraint system (as frontend.Compile
+// with r1cs.NewBuilder produces it for the BN254 scalar field) from path,
+// for a caller that compiled it once and serialized it with
+// constraint.ConstraintSystem.WriteTo, so Init doesn't need to depend on
+// any particular circuit package to re-derive it.
+func ReadR1CS(path string) (constraint.ConstraintSystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cs := bn254cs.NewR1CS(0)
+	if _, err := cs.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read constraint system: %w", err)
+	}
+	return cs, nil
+}
+
+// Contribute reads the ceremony transcript at inFile, samples a fresh
+// contributor secret (delta) from crypto/rand, folds it into [delta]_1,
+// [delta]_2, the L query (the private-witness Lagrange terms, each divided
+// by the previous delta and multiplied by the new one) and the H query,
+// attaches a proof of knowledge of delta derived from a hash-to-curve
+// challenge over the transcript so far, and writes the extended transcript
+// to outFile.
+//
+// mpcsetup.Phase2.Contribute draws its secret and proof-of-knowledge
+// challenge from crypto/rand.Reader internally; gnark-crypto does not expose
+// a lower-level entry point to supply the randomness or challenge from the
+// caller side without reimplementing its MPC internals, so Contribute has no
+// entropy parameter to thread through.
+func Contribute(inFile, outFile string) error {
+	transcript, err := readTranscript(inFile)
+	if err != nil {
+		return fmt.Errorf("read transcript: %w", err)
+	}
+
+	var phase2 mpcsetup.Phase2
+	if len(transcript.Phase2Contributions) == 0 {
+		phase2.Initialize(transcript.CS, &transcript.Phase1)
+	} else {
+		phase2 = transcript.Phase2Contributions[len(transcript.Phase2Contributions)-1]
+	}
+
+	next := phase2.Contribute()
+
+	transcript.Phase2Contributions = append(transcript.Phase2Contributions, next)
+	return writeTranscript(outFile, transcript)
+}
+
+// Verify checks every phase-2 contribution in the transcript against the
+// previous one's proof of knowledge (and the first against the phase-1
+// contribution it was initialized from), so any party can confirm the
+// ceremony was run honestly without trusting a single contributor.
+func Verify(transcriptFile string) error {
+	transcript, err := readTranscript(transcriptFile)
+	if err != nil {
+		return fmt.Errorf("read transcript: %w", err)
+	}
+	if len(transcript.Phase2Contributions) == 0 {
+		return fmt.Errorf("transcript has no phase-2 contributions")
+	}
+
+	var initial mpcsetup.Phase2
+	initial.Initialize(transcript.CS, &transcript.Phase1)
+	if err := mpcsetup.VerifyPhase2(&initial, &transcript.Phase2Contributions[0]); err != nil {
+		return fmt.Errorf("contribution 0 failed verification against phase1: %w", err)
+	}
+
+	for i := 1; i < len(transcript.Phase2Contributions); i++ {
+		prev, next := transcript.Phase2Contributions[i-1], transcript.Phase2Contributions[i]
+		if err := mpcsetup.VerifyPhase2(&prev, &next); err != nil {
+			return fmt.Errorf("contribution %d failed verification: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Finalize seals the last verified phase-2 contribution together with the
+// phase-1 Powers-of-Tau SRS into a Groth16 ProvingKey/VerifyingKey pair, for
+// use in place of groth16.DummySetup. Finalize only returns the pair; it
+// writes nothing to disk (the "zkm ceremony finalize" command persists it as
+// groth16_pk.bin/groth16_vk.bin).
+func Finalize(transcriptFile string) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	transcript, err := readTranscript(transcriptFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read transcript: %w", err)
+	}
+	if err := Verify(transcriptFile); err != nil {
+		return nil, nil, fmt.Errorf("verify transcript before finalizing: %w", err)
+	}
+
+	last := transcript.Phase2Contributions[len(transcript.Phase2Contributions)-1]
+	pk, vk := last.Seal(&transcript.Phase1, transcript.CS)
+
+	return &pk, &vk, nil
+}
+
+func readTranscript(path string) (*Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cs := bn254cs.NewR1CS(0)
+	if _, err := cs.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read constraint system: %w", err)
+	}
+
+	transcript := &Transcript{CS: cs}
+	if _, err := transcript.Phase1.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read phase1 contribution: %w", err)
+	}
+	for i := 0; ; i++ {
+		var phase2 mpcsetup.Phase2
+		if _, err := phase2.ReadFrom(f); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("read phase2 contribution %d: %w", i, err)
+		}
+		transcript.Phase2Contributions = append(transcript.Phase2Contributions, phase2)
+	}
+	return transcript, nil
+}
+
+func writeTranscript(path string, transcript *Transcript) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if transcript.CS == nil {
+		return fmt.Errorf("write transcript: constraint system is required")
+	}
+	if _, err := transcript.CS.WriteTo(f); err != nil {
+		return fmt.Errorf("write constraint system: %w", err)
+	}
+	if _, err := transcript.Phase1.WriteTo(f); err != nil {
+		return fmt.Errorf("write phase1 contribution: %w", err)
+	}
+	for i, phase2 := range transcript.Phase2Contributions {
+		if _, err := phase2.WriteTo(f); err != nil {
+			return fmt.Errorf("write phase2 contribution %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package ceremony
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+// ContributePlonkSRS runs a single phase-2 contribution against a PLONK KZG
+// SRS: it samples a fresh secret tau, raises every power-of-tau commitment
+// in the SRS by it, and writes the updated SRS to outFile. Unlike Groth16's
+// phase-2, PLONK's SRS is not circuit-specific, so the same ceremony output
+// can be reused by plonk.Setup for any circuit compiled against this
+// package's circuits.
+//
+// fr.Element.SetRandom draws from crypto/rand.Reader internally with no way
+// to supply caller entropy, so ContributePlonkSRS has no entropy parameter
+// to thread through (mirroring Contribute in ceremony.go, for the same
+// reason).
+func ContributePlonkSRS(inFile, outFile string) error {
+	srs, err := readKZGSRS(inFile)
+	if err != nil {
+		return fmt.Errorf("read srs: %w", err)
+	}
+
+	var tau fr.Element
+	if _, err := tau.SetRandom(); err != nil {
+		return fmt.Errorf("sample tau: %w", err)
+	}
+
+	if err := srs.Pk.Update(&tau); err != nil {
+		return fmt.Errorf("update srs proving key: %w", err)
+	}
+	if err := srs.Vk.Update(&tau); err != nil {
+		return fmt.Errorf("update srs verifying key: %w", err)
+	}
+
+	return writeKZGSRS(outFile, srs)
+}
+
+// FinalizePlonkSRS reads back the SRS at srsFile after its last
+// ContributePlonkSRS call, the PLONK analogue of Finalize. Unlike Groth16's
+// phase-2, a PLONK KZG SRS is not circuit-specific and has no Seal step
+// combining it with a constraint system, so each ContributePlonkSRS call
+// already leaves srsFile in the form plonk.Setup consumes directly;
+// FinalizePlonkSRS exists to give callers the same load-and-use shape as
+// Finalize.
+func FinalizePlonkSRS(srsFile string) (*kzg.SRS, error) {
+	return readKZGSRS(srsFile)
+}
+
+func readKZGSRS(path string) (*kzg.SRS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	srs := &kzg.SRS{}
+	if _, err := srs.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return srs, nil
+}
+
+func writeKZGSRS(path string, srs *kzg.SRS) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = srs.WriteTo(f)
+	return err
+}
@@ -0,0 +1,27 @@
+package zkm
+
+import (
+	"github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/backend/groth16"
+
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/ceremony"
+)
+
+// LoadCeremonyGroth16Keys finalizes the phase-2 ceremony transcript at
+// transcriptPath into a ProvingKey/VerifyingKey pair. It is what the
+// "ceremony finalize" CLI subcommand calls to persist groth16_pk.bin/
+// groth16_vk.bin to a data dir, the same files RunConstraintTest and
+// ProveGroth16WithOptions load in place of an unsafe setup whenever that
+// data dir is supplied.
+func LoadCeremonyGroth16Keys(transcriptPath string) (groth16.ProvingKey, groth16.VerifyingKey, error) {
+	return ceremony.Finalize(transcriptPath)
+}
+
+// LoadCeremonyPlonkSRS is the PLONK counterpart of LoadCeremonyGroth16Keys:
+// it loads the KZG SRS produced by a series of ceremony.ContributePlonkSRS
+// calls at srsPath. It is what the "ceremony finalize-plonk" CLI subcommand
+// calls to copy that SRS to plonk_srs.bin in a data dir. BuildPlonk does not
+// read this SRS.
+func LoadCeremonyPlonkSRS(srsPath string) (*kzg.SRS, error) {
+	return ceremony.FinalizePlonkSRS(srsPath)
+}
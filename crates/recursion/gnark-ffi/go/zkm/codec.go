@@ -0,0 +1,148 @@
+package zkm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// varLimbBytes/feltLimbBytes/extLimbBytes are the fixed widths DecodeWitness
+// and EncodeWitness use for each WitnessInput element: a Var is a BN254
+// scalar (fits in 32 bytes), a Felt is a KoalaBear element (fits comfortably
+// in 4 bytes), and an Ext is a packed KoalaBear extension element as
+// accepted by koalabear.NewE (fits in 16 bytes).
+const (
+	varLimbBytes  = 32
+	feltLimbBytes = 4
+	extLimbBytes  = 16
+)
+
+// EncodeWitness writes a WitnessInput to w in the binary codec DecodeWitness
+// reads back: a length-prefixed string for VkeyHash and
+// CommittedValuesDigest, then a little-endian u32 count followed by that
+// many fixed-width limbs for each of Vars, Felts and Exts. This avoids the
+// allocation and parsing overhead of encoding/json on the large Vars/Felts
+// arrays a recursion witness carries.
+func EncodeWitness(w io.Writer, input WitnessInput) error {
+	if err := writeString(w, input.VkeyHash); err != nil {
+		return fmt.Errorf("write VkeyHash: %w", err)
+	}
+	if err := writeString(w, input.CommittedValuesDigest); err != nil {
+		return fmt.Errorf("write CommittedValuesDigest: %w", err)
+	}
+	if err := writeLimbs(w, input.Vars, varLimbBytes); err != nil {
+		return fmt.Errorf("write Vars: %w", err)
+	}
+	if err := writeLimbs(w, input.Felts, feltLimbBytes); err != nil {
+		return fmt.Errorf("write Felts: %w", err)
+	}
+	if err := writeLimbs(w, input.Exts, extLimbBytes); err != nil {
+		return fmt.Errorf("write Exts: %w", err)
+	}
+	return nil
+}
+
+// DecodeWitness reads a WitnessInput back from the binary codec written by
+// EncodeWitness. It is the streaming replacement for
+// json.Unmarshal(data, &WitnessInput{}): same logical content, but callers
+// such as ProvePlonkWithOptions can feed it directly from a buffer a Rust
+// caller already holds in memory instead of a file path.
+func DecodeWitness(r io.Reader) (WitnessInput, error) {
+	vkeyHash, err := readString(r)
+	if err != nil {
+		return WitnessInput{}, fmt.Errorf("read VkeyHash: %w", err)
+	}
+	committedValuesDigest, err := readString(r)
+	if err != nil {
+		return WitnessInput{}, fmt.Errorf("read CommittedValuesDigest: %w", err)
+	}
+	vars, err := readLimbs(r, varLimbBytes)
+	if err != nil {
+		return WitnessInput{}, fmt.Errorf("read Vars: %w", err)
+	}
+	felts, err := readLimbs(r, feltLimbBytes)
+	if err != nil {
+		return WitnessInput{}, fmt.Errorf("read Felts: %w", err)
+	}
+	exts, err := readLimbs(r, extLimbBytes)
+	if err != nil {
+		return WitnessInput{}, fmt.Errorf("read Exts: %w", err)
+	}
+
+	return WitnessInput{
+		VkeyHash:              vkeyHash,
+		CommittedValuesDigest: committedValuesDigest,
+		Vars:                  vars,
+		Felts:                 felts,
+		Exts:                  exts,
+	}, nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readString(r io.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// writeLimbs encodes each element of vals (a decimal string, as
+// WitnessInput stores them) as a limbWidth-byte big-endian integer.
+func writeLimbs(w io.Writer, vals []string, limbWidth int) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(vals))); err != nil {
+		return err
+	}
+	limb := make([]byte, limbWidth)
+	for i, v := range vals {
+		n, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return fmt.Errorf("element %d: %q is not a decimal integer", i, v)
+		}
+		if n.BitLen() > limbWidth*8 {
+			return fmt.Errorf("element %d: %q overflows %d-byte limb", i, v, limbWidth)
+		}
+		n.FillBytes(limb)
+		if _, err := w.Write(limb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxWitnessElements bounds a single Vars/Felts/Exts count read off the
+// wire, so a corrupt or truncated witness buffer (e.g. a length mismatch
+// from a streaming Rust caller) fails with a clean decode error instead of
+// driving make([]string, n) to attempt a multi-gigabyte allocation.
+const maxWitnessElements = 1 << 24
+
+func readLimbs(r io.Reader, limbWidth int) ([]string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > maxWitnessElements {
+		return nil, fmt.Errorf("element count %d exceeds maximum %d", n, maxWitnessElements)
+	}
+	vals := make([]string, n)
+	limb := make([]byte, limbWidth)
+	for i := range vals {
+		if _, err := io.ReadFull(r, limb); err != nil {
+			return nil, err
+		}
+		vals[i] = new(big.Int).SetBytes(limb).String()
+	}
+	return vals, nil
+}
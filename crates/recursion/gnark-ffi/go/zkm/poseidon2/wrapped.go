@@ -0,0 +1,307 @@
+package poseidon2
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12377fr "github.com/consensys/gnark-crypto/ecc/bls12-377/fr"
+	bls12377poseidon2 "github.com/consensys/gnark-crypto/ecc/bls12-377/fr/poseidon2"
+	bn254fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	bn254poseidon2 "github.com/consensys/gnark-crypto/ecc/bn254/fr/poseidon2"
+	bw6761fr "github.com/consensys/gnark-crypto/ecc/bw6-761/fr"
+	bw6761poseidon2 "github.com/consensys/gnark-crypto/ecc/bw6-761/fr/poseidon2"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/emulated"
+	stdposeidon2 "github.com/consensys/gnark/std/permutation/poseidon2"
+
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/koalabear"
+)
+
+// wrappedSpongeWidth/Rate/FullRounds/PartialRounds are the Poseidon2 sponge
+// parameters used to absorb an arbitrary number of outer-field limbs and
+// squeeze a single digest element, independent of which outer field FR
+// instantiates the sponge. They must stay in sync between Hash (in-circuit)
+// and nativeSpongeRef (out-of-circuit) for the two to agree bit-for-bit.
+const (
+	wrappedSpongeWidth   = 3
+	wrappedSpongeRate    = 2
+	wrappedFullRounds    = 8
+	wrappedPartialRounds = 56
+)
+
+// domainSeparator tags the wrapped-hash transcript so it cannot collide with
+// any other Poseidon2 usage (e.g. the native KoalaBear-over-KoalaBear sponge
+// used by NewKoalaBearChip) over the same outer field.
+const domainSeparator = "zkMIPS-wrapped-koalabear-v1"
+
+func domainSeparatorConstant() *big.Int {
+	sum := sha256.Sum256([]byte(domainSeparator))
+	return new(big.Int).SetBytes(sum[:])
+}
+
+// WrappedKoalaBearChip hashes KoalaBear field elements using a Poseidon2
+// sponge instantiated over an outer curve's scalar field FR, so a circuit
+// whose native field is not KoalaBear-friendly (e.g. a BW6-761 circuit
+// verifying a transcript built over a BLS12-377/KoalaBear inner proof) can
+// still absorb and verify that transcript. Each KoalaBear element is
+// marshalled to its canonical native representation in the enclosing
+// circuit's field (KoalaBear's 31-bit modulus fits in any field this
+// package targets) before being absorbed, following the same fixed-width,
+// domain-separated encoding as HashKoalaBearElements.
+type WrappedKoalaBearChip[FR emulated.FieldParams] struct {
+	api   frontend.API
+	field *emulated.Field[FR]
+}
+
+// NewWrappedKoalaBear builds a WrappedKoalaBearChip over the outer field FR
+// (the scalar field the enclosing circuit is compiled for, identified at
+// the call site by outerField so the caller and HashKoalaBearElements agree
+// on which native permutation backs the sponge) that hashes values drawn
+// from the KoalaBear field innerField.
+func NewWrappedKoalaBear[FR emulated.FieldParams](api frontend.API, outerField ecc.ID, innerField *big.Int) (*WrappedKoalaBearChip[FR], error) {
+	if _, err := nativePermutationFor(outerField); err != nil {
+		return nil, err
+	}
+	field, err := emulated.NewField[FR](api)
+	if err != nil {
+		return nil, err
+	}
+	return &WrappedKoalaBearChip[FR]{api: api, field: field}, nil
+}
+
+// Hash absorbs a domain-separated sequence of KoalaBear elements into a
+// Poseidon2 sponge over FR and returns the digest as a single emulated
+// outer-field element. It must agree bit-for-bit with HashKoalaBearElements
+// evaluated (with the matching outerField) over the same elements' canonical
+// big.Int values.
+func (c *WrappedKoalaBearChip[FR]) Hash(elems []koalabear.Variable) (*emulated.Element[FR], error) {
+	chip := koalabear.NewChip(c.api)
+
+	limbs := make([]frontend.Variable, 0, len(elems)+1)
+	limbs = append(limbs, domainSeparatorConstant())
+	for _, e := range elems {
+		limbs = append(limbs, chip.ToNativeVariable(e))
+	}
+
+	digest, err := circuitSponge(c.api, limbs)
+	if err != nil {
+		return nil, err
+	}
+	return c.field.NewElement(digest), nil
+}
+
+// HashKoalaBearElements is the native (out-of-circuit) reference
+// implementation of WrappedKoalaBearChip.Hash: it must agree bit-for-bit
+// with the in-circuit hash, for the same outerField, over any sequence of
+// KoalaBear elements' canonical representatives, so a verifier can
+// recompute the expected digest without running the circuit.
+func HashKoalaBearElements(outerField ecc.ID, elems []*big.Int) (*big.Int, error) {
+	limbs := make([]*big.Int, 0, len(elems)+1)
+	limbs = append(limbs, domainSeparatorConstant())
+	limbs = append(limbs, elems...)
+	return nativeSpongeRef(outerField, limbs)
+}
+
+// HashExt is Hash's counterpart for KoalaBear extension elements (as
+// constructed by koalabear.NewE): each element packs its degree-4
+// representation into a single field element (extLimbBytes in the zkm
+// package's codec fits that packed value in 16 bytes), so it is absorbed
+// as one native limb per element, exactly like Hash absorbs one limb per
+// koalabear.Variable. It must agree bit-for-bit with
+// HashKoalaBearExtensionElements evaluated (with the matching outerField)
+// over the same elements' canonical packed big.Int values.
+func (c *WrappedKoalaBearChip[FR]) HashExt(elems []koalabear.ExtensionVariable) (*emulated.Element[FR], error) {
+	chip := koalabear.NewChip(c.api)
+
+	limbs := make([]frontend.Variable, 0, len(elems)+1)
+	limbs = append(limbs, domainSeparatorConstant())
+	for _, e := range elems {
+		limbs = append(limbs, chip.ToNativeExtensionVariable(e))
+	}
+
+	digest, err := circuitSponge(c.api, limbs)
+	if err != nil {
+		return nil, err
+	}
+	return c.field.NewElement(digest), nil
+}
+
+// HashKoalaBearExtensionElements is the native (out-of-circuit) reference
+// implementation of WrappedKoalaBearChip.HashExt: it must agree bit-for-bit
+// with the in-circuit hash, for the same outerField, over any sequence of
+// packed KoalaBear extension elements' canonical big.Int values.
+func HashKoalaBearExtensionElements(outerField ecc.ID, elems []*big.Int) (*big.Int, error) {
+	limbs := make([]*big.Int, 0, len(elems)+1)
+	limbs = append(limbs, domainSeparatorConstant())
+	limbs = append(limbs, elems...)
+	return nativeSpongeRef(outerField, limbs)
+}
+
+// circuitSponge absorbs limbs (rate elements at a time, zero-padding the
+// final block) into a Poseidon2 permutation over the circuit's native field
+// and squeezes a single output element.
+func circuitSponge(api frontend.API, limbs []frontend.Variable) (frontend.Variable, error) {
+	perm, err := stdposeidon2.NewPoseidon2(api, wrappedSpongeWidth, wrappedFullRounds, wrappedPartialRounds)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make([]frontend.Variable, wrappedSpongeWidth)
+	for i := range state {
+		state[i] = frontend.Variable(0)
+	}
+
+	for i := 0; i < len(limbs); i += wrappedSpongeRate {
+		end := i + wrappedSpongeRate
+		if end > len(limbs) {
+			end = len(limbs)
+		}
+		for j, limb := range limbs[i:end] {
+			state[j] = api.Add(state[j], limb)
+		}
+		if err := perm.Permutation(state); err != nil {
+			return nil, err
+		}
+	}
+
+	return state[0], nil
+}
+
+// nativePermutation is the out-of-circuit counterpart of
+// stdposeidon2.NewPoseidon2: it runs the same width/full-round/partial-round
+// Poseidon2 permutation, but over a concrete curve's scalar field rather
+// than a circuit's native field, so HashKoalaBearElements can reproduce
+// circuitSponge's arithmetic exactly.
+type nativePermutation interface {
+	absorb(state []*big.Int, limb *big.Int, pos int) []*big.Int
+	permute(state []*big.Int) ([]*big.Int, error)
+	modulus() *big.Int
+}
+
+// nativePermutationFor resolves outerField to the gnark-crypto Poseidon2
+// permutation for that curve's scalar field, or an error if outerField is
+// not one of the outer curves this package supports (currently BN254, for
+// wrapping a BN254 outer proof, and BLS12-377/BW6-761, for wrapping zkMIPS
+// Fiat-Shamir transcripts into a non-BN254 outer proof).
+func nativePermutationFor(outerField ecc.ID) (nativePermutation, error) {
+	switch outerField {
+	case ecc.BN254:
+		return bn254Permutation{bn254poseidon2.NewPermutation(wrappedSpongeWidth, wrappedFullRounds, wrappedPartialRounds)}, nil
+	case ecc.BLS12_377:
+		return bls12377Permutation{bls12377poseidon2.NewPermutation(wrappedSpongeWidth, wrappedFullRounds, wrappedPartialRounds)}, nil
+	case ecc.BW6_761:
+		return bw6761Permutation{bw6761poseidon2.NewPermutation(wrappedSpongeWidth, wrappedFullRounds, wrappedPartialRounds)}, nil
+	default:
+		return nil, fmt.Errorf("poseidon2: unsupported wrapped-hash outer field %s", outerField)
+	}
+}
+
+type bn254Permutation struct{ perm *bn254poseidon2.Permutation }
+
+func (bn254Permutation) modulus() *big.Int { return bn254fr.Modulus() }
+
+func (bn254Permutation) absorb(state []*big.Int, limb *big.Int, pos int) []*big.Int {
+	state[pos] = new(big.Int).Mod(new(big.Int).Add(state[pos], limb), bn254fr.Modulus())
+	return state
+}
+
+func (p bn254Permutation) permute(state []*big.Int) ([]*big.Int, error) {
+	elems := make([]bn254fr.Element, len(state))
+	for i, v := range state {
+		elems[i].SetBigInt(v)
+	}
+	if err := p.perm.Permutation(elems); err != nil {
+		return nil, err
+	}
+	out := make([]*big.Int, len(elems))
+	for i := range elems {
+		out[i] = new(big.Int)
+		elems[i].BigInt(out[i])
+	}
+	return out, nil
+}
+
+type bls12377Permutation struct {
+	perm *bls12377poseidon2.Permutation
+}
+
+func (bls12377Permutation) modulus() *big.Int { return bls12377fr.Modulus() }
+
+func (bls12377Permutation) absorb(state []*big.Int, limb *big.Int, pos int) []*big.Int {
+	state[pos] = new(big.Int).Mod(new(big.Int).Add(state[pos], limb), bls12377fr.Modulus())
+	return state
+}
+
+func (p bls12377Permutation) permute(state []*big.Int) ([]*big.Int, error) {
+	elems := make([]bls12377fr.Element, len(state))
+	for i, v := range state {
+		elems[i].SetBigInt(v)
+	}
+	if err := p.perm.Permutation(elems); err != nil {
+		return nil, err
+	}
+	out := make([]*big.Int, len(elems))
+	for i := range elems {
+		out[i] = new(big.Int)
+		elems[i].BigInt(out[i])
+	}
+	return out, nil
+}
+
+type bw6761Permutation struct{ perm *bw6761poseidon2.Permutation }
+
+func (bw6761Permutation) modulus() *big.Int { return bw6761fr.Modulus() }
+
+func (bw6761Permutation) absorb(state []*big.Int, limb *big.Int, pos int) []*big.Int {
+	state[pos] = new(big.Int).Mod(new(big.Int).Add(state[pos], limb), bw6761fr.Modulus())
+	return state
+}
+
+func (p bw6761Permutation) permute(state []*big.Int) ([]*big.Int, error) {
+	elems := make([]bw6761fr.Element, len(state))
+	for i, v := range state {
+		elems[i].SetBigInt(v)
+	}
+	if err := p.perm.Permutation(elems); err != nil {
+		return nil, err
+	}
+	out := make([]*big.Int, len(elems))
+	for i := range elems {
+		out[i] = new(big.Int)
+		elems[i].BigInt(out[i])
+	}
+	return out, nil
+}
+
+// nativeSpongeRef is the native counterpart of circuitSponge: it absorbs
+// limbs (rate elements at a time, zero-padding the final block) into the
+// outerField Poseidon2 permutation and squeezes a single output element.
+func nativeSpongeRef(outerField ecc.ID, limbs []*big.Int) (*big.Int, error) {
+	perm, err := nativePermutationFor(outerField)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make([]*big.Int, wrappedSpongeWidth)
+	for i := range state {
+		state[i] = new(big.Int)
+	}
+
+	for i := 0; i < len(limbs); i += wrappedSpongeRate {
+		end := i + wrappedSpongeRate
+		if end > len(limbs) {
+			end = len(limbs)
+		}
+		for j, limb := range limbs[i:end] {
+			state = perm.absorb(state, limb, j)
+		}
+		state, err = perm.permute(state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state[0], nil
+}
@@ -0,0 +1,231 @@
+package poseidon2
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/test"
+
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/koalabear"
+)
+
+// koalaBearModulus is the KoalaBear prime 2^31 - 2^24 + 1, the innerField
+// every wrappedHashCircuit passes to NewWrappedKoalaBear below.
+var koalaBearModulus = big.NewInt(2130706433)
+
+// wrappedHashCircuit exercises WrappedKoalaBearChip.Hash over Elems and
+// asserts the result equals Expected, so the TestWrappedKoalaBearHash_*
+// cases below can feed it the off-circuit HashKoalaBearElements result for
+// the same OuterField and elements and confirm the in-circuit and native
+// implementations agree bit-for-bit.
+type wrappedHashCircuit[FR emulated.FieldParams] struct {
+	Elems      [4]koalabear.Variable
+	Expected   emulated.Element[FR] `gnark:",public"`
+	OuterField ecc.ID               `gnark:"-"`
+}
+
+func (c *wrappedHashCircuit[FR]) Define(api frontend.API) error {
+	chip, err := NewWrappedKoalaBear[FR](api, c.OuterField, koalaBearModulus)
+	if err != nil {
+		return err
+	}
+	digest, err := chip.Hash(c.Elems[:])
+	if err != nil {
+		return err
+	}
+	field, err := emulated.NewField[FR](api)
+	if err != nil {
+		return err
+	}
+	field.AssertIsEqual(digest, &c.Expected)
+	return nil
+}
+
+// testElemValues is a fixed, arbitrary set of KoalaBear elements absorbed by
+// every TestWrappedKoalaBearHash_* case below.
+var testElemValues = []int64{1, 2, 3, 4}
+
+func testElems() [4]koalabear.Variable {
+	var elems [4]koalabear.Variable
+	for i, v := range testElemValues {
+		elems[i] = koalabear.NewF(big.NewInt(v).String())
+	}
+	return elems
+}
+
+func testElemBigInts() []*big.Int {
+	elems := make([]*big.Int, len(testElemValues))
+	for i, v := range testElemValues {
+		elems[i] = big.NewInt(v)
+	}
+	return elems
+}
+
+// TestWrappedKoalaBearHash_BLS12377 confirms WrappedKoalaBearChip.Hash
+// agrees bit-for-bit with HashKoalaBearElements when BLS12-377 is the outer
+// field wrapping a KoalaBear (zkMIPS) transcript: the first leg of the
+// BN254 -> BLS12-377 -> BW6-761 recursion chain this package supports.
+func TestWrappedKoalaBearHash_BLS12377(t *testing.T) {
+	testWrappedKoalaBearHash(t, ecc.BLS12_377)
+}
+
+// TestWrappedKoalaBearHash_BW6761 confirms the same agreement when
+// BW6-761 is the outer field: the second leg of the chain, wrapping a
+// circuit that itself verifies a BLS12-377 proof.
+func TestWrappedKoalaBearHash_BW6761(t *testing.T) {
+	testWrappedKoalaBearHash(t, ecc.BW6_761)
+}
+
+// TestWrappedKoalaBearHash_BN254 confirms the same agreement when BN254 is
+// the outer field: RecursiveWrapCircuit (zkm/wrap.go) and BatchCircuit
+// (zkm/batch.go) both wrap zkMIPS transcripts directly into a BN254 proof,
+// with no BLS12-377/BW6-761 leg in between.
+func TestWrappedKoalaBearHash_BN254(t *testing.T) {
+	testWrappedKoalaBearHash(t, ecc.BN254)
+}
+
+func testWrappedKoalaBearHash(t *testing.T, outerField ecc.ID) {
+	expected, err := HashKoalaBearElements(outerField, testElemBigInts())
+	if err != nil {
+		t.Fatalf("HashKoalaBearElements: %v", err)
+	}
+
+	switch outerField {
+	case ecc.BN254:
+		runWrappedKoalaBearHash[emulated.BN254Fr](t, outerField, expected)
+	case ecc.BLS12_377:
+		runWrappedKoalaBearHash[emulated.BLS12377Fr](t, outerField, expected)
+	case ecc.BW6_761:
+		runWrappedKoalaBearHash[emulated.BW6761Fr](t, outerField, expected)
+	default:
+		t.Fatalf("unsupported outer field %s", outerField)
+	}
+}
+
+// runWrappedKoalaBearHash compiles wrappedHashCircuit[FR] natively over
+// outerField itself, not BN254: circuitSponge runs the sponge's additions
+// and permutation through the enclosing circuit's native frontend.API ops,
+// so it only actually reduces mod FR and uses FR's Poseidon2 round
+// constants when the circuit is compiled with outerField.ScalarField() as
+// its native field. Compiling over BN254 for every FR (as this test used
+// to) would silently run BN254 arithmetic for the BLS12-377/BW6-761 cases
+// and could only agree with nativeSpongeRef(outerField, ...) by
+// coincidence; compiling over outerField is what makes a passing solve
+// direct evidence the in-circuit and native implementations of the wrapped
+// hash agree bit-for-bit.
+func runWrappedKoalaBearHash[FR emulated.FieldParams](t *testing.T, outerField ecc.ID, expected *big.Int) {
+	circuit := &wrappedHashCircuit[FR]{OuterField: outerField}
+	assignment := &wrappedHashCircuit[FR]{
+		Elems:      testElems(),
+		Expected:   emulated.ValueOf[FR](expected),
+		OuterField: outerField,
+	}
+
+	assert := test.NewAssert(t)
+	assert.SolvingSucceeded(circuit, assignment, test.WithCurves(outerField))
+}
+
+// wrappedHashExtCircuit is wrappedHashCircuit's counterpart for
+// WrappedKoalaBearChip.HashExt, exercising the packed-extension-element
+// entry point over Elems instead of plain KoalaBear variables.
+type wrappedHashExtCircuit[FR emulated.FieldParams] struct {
+	Elems      [4]koalabear.ExtensionVariable
+	Expected   emulated.Element[FR] `gnark:",public"`
+	OuterField ecc.ID               `gnark:"-"`
+}
+
+func (c *wrappedHashExtCircuit[FR]) Define(api frontend.API) error {
+	chip, err := NewWrappedKoalaBear[FR](api, c.OuterField, koalaBearModulus)
+	if err != nil {
+		return err
+	}
+	digest, err := chip.HashExt(c.Elems[:])
+	if err != nil {
+		return err
+	}
+	field, err := emulated.NewField[FR](api)
+	if err != nil {
+		return err
+	}
+	field.AssertIsEqual(digest, &c.Expected)
+	return nil
+}
+
+// testExtElemValues is testElemValues' counterpart for
+// TestWrappedKoalaBearHashExt_*: a fixed, arbitrary set of packed KoalaBear
+// extension element values, distinct from testElemValues so a regression
+// that accidentally reused Hash's sponge inputs for HashExt would still be
+// caught.
+var testExtElemValues = []int64{5, 6, 7, 8}
+
+func testExtElems() [4]koalabear.ExtensionVariable {
+	var elems [4]koalabear.ExtensionVariable
+	for i, v := range testExtElemValues {
+		elems[i] = koalabear.NewE(big.NewInt(v).String())
+	}
+	return elems
+}
+
+func testExtElemBigInts() []*big.Int {
+	elems := make([]*big.Int, len(testExtElemValues))
+	for i, v := range testExtElemValues {
+		elems[i] = big.NewInt(v)
+	}
+	return elems
+}
+
+// TestWrappedKoalaBearHashExt_BLS12377 confirms
+// WrappedKoalaBearChip.HashExt agrees bit-for-bit with
+// HashKoalaBearExtensionElements over BLS12-377, mirroring
+// TestWrappedKoalaBearHash_BLS12377 for the extension-element entry point.
+func TestWrappedKoalaBearHashExt_BLS12377(t *testing.T) {
+	testWrappedKoalaBearHashExt(t, ecc.BLS12_377)
+}
+
+// TestWrappedKoalaBearHashExt_BW6761 is TestWrappedKoalaBearHashExt_BLS12377's
+// BW6-761 counterpart.
+func TestWrappedKoalaBearHashExt_BW6761(t *testing.T) {
+	testWrappedKoalaBearHashExt(t, ecc.BW6_761)
+}
+
+// TestWrappedKoalaBearHashExt_BN254 is TestWrappedKoalaBearHashExt_BLS12377's
+// BN254 counterpart.
+func TestWrappedKoalaBearHashExt_BN254(t *testing.T) {
+	testWrappedKoalaBearHashExt(t, ecc.BN254)
+}
+
+func testWrappedKoalaBearHashExt(t *testing.T, outerField ecc.ID) {
+	expected, err := HashKoalaBearExtensionElements(outerField, testExtElemBigInts())
+	if err != nil {
+		t.Fatalf("HashKoalaBearExtensionElements: %v", err)
+	}
+
+	switch outerField {
+	case ecc.BN254:
+		runWrappedKoalaBearHashExt[emulated.BN254Fr](t, outerField, expected)
+	case ecc.BLS12_377:
+		runWrappedKoalaBearHashExt[emulated.BLS12377Fr](t, outerField, expected)
+	case ecc.BW6_761:
+		runWrappedKoalaBearHashExt[emulated.BW6761Fr](t, outerField, expected)
+	default:
+		t.Fatalf("unsupported outer field %s", outerField)
+	}
+}
+
+// runWrappedKoalaBearHashExt is runWrappedKoalaBearHash's counterpart for
+// wrappedHashExtCircuit; see runWrappedKoalaBearHash for why it compiles
+// natively over outerField rather than always over BN254.
+func runWrappedKoalaBearHashExt[FR emulated.FieldParams](t *testing.T, outerField ecc.ID, expected *big.Int) {
+	circuit := &wrappedHashExtCircuit[FR]{OuterField: outerField}
+	assignment := &wrappedHashExtCircuit[FR]{
+		Elems:      testExtElems(),
+		Expected:   emulated.ValueOf[FR](expected),
+		OuterField: outerField,
+	}
+
+	assert := test.NewAssert(t)
+	assert.SolvingSucceeded(circuit, assignment, test.WithCurves(outerField))
+}
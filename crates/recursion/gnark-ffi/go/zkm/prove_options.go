@@ -0,0 +1,274 @@
+package zkm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	native_groth16 "github.com/consensys/gnark/backend/groth16"
+	native_plonk "github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+// ProveOptions configures a proving or constraint-test run without relying
+// on the WITNESS_JSON/CONSTRAINTS_JSON/GROTH16 environment-variable globals
+// RunConstraintTest previously read: every caller, including concurrent
+// ones on the same process, now threads its own witness/constraints/backend
+// through this struct instead of mutating shared process state.
+type ProveOptions struct {
+	// DataDir is the directory BuildPlonk/BuildGroth16 persisted the
+	// circuit's proving key to.
+	DataDir string
+	// WitnessReader supplies the witness in the binary format DecodeWitness
+	// reads (see codec.go), replacing the WITNESS_JSON file path.
+	WitnessReader io.Reader
+	// ConstraintsReader, if set, supplies a small JSON object
+	// ({"constraints": N}) RunConstraintTest checks the compiled circuit's
+	// constraint count against, replacing the CONSTRAINTS_JSON file path.
+	ConstraintsReader io.Reader
+	// Backend selects the proving system: "plonk" (default, if empty) or
+	// "groth16".
+	Backend string
+}
+
+func (opts ProveOptions) isGroth16() bool {
+	return opts.Backend == "groth16"
+}
+
+// ProvePlonkWithOptions is the streaming counterpart of ProvePlonk: it reads
+// the witness from opts.WitnessReader via DecodeWitness instead of parsing
+// a WITNESS_JSON file, so a caller that already holds the witness in memory
+// (e.g. streaming it in from Rust) never has to round-trip it through disk
+// or encoding/json. It otherwise proves exactly as ProvePlonk does, loading
+// the circuit's proving key from opts.DataDir.
+func ProvePlonkWithOptions(opts ProveOptions) (Proof, error) {
+	if opts.WitnessReader == nil {
+		return Proof{}, fmt.Errorf("ProvePlonkWithOptions: WitnessReader is required")
+	}
+
+	inputs, err := DecodeWitness(opts.WitnessReader)
+	if err != nil {
+		return Proof{}, fmt.Errorf("decode witness: %w", err)
+	}
+
+	circuit := NewCircuit(inputs)
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	if err != nil {
+		return Proof{}, fmt.Errorf("compile circuit: %w", err)
+	}
+
+	pk, err := loadPlonkProvingKey(opts.DataDir, ccs)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	assignment := NewCircuit(inputs)
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return Proof{}, fmt.Errorf("build witness: %w", err)
+	}
+
+	proof, err := native_plonk.Prove(ccs, pk, w)
+	if err != nil {
+		return Proof{}, fmt.Errorf("prove: %w", err)
+	}
+
+	return NewZKMPlonkBn254Proof(&proof, inputs), nil
+}
+
+// ProveGroth16WithOptions is the Groth16 counterpart of
+// ProvePlonkWithOptions.
+func ProveGroth16WithOptions(opts ProveOptions) (Proof, error) {
+	if opts.WitnessReader == nil {
+		return Proof{}, fmt.Errorf("ProveGroth16WithOptions: WitnessReader is required")
+	}
+
+	inputs, err := DecodeWitness(opts.WitnessReader)
+	if err != nil {
+		return Proof{}, fmt.Errorf("decode witness: %w", err)
+	}
+
+	circuit := NewCircuit(inputs)
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	if err != nil {
+		return Proof{}, fmt.Errorf("compile circuit: %w", err)
+	}
+
+	pk, err := loadGroth16ProvingKey(opts.DataDir, ccs)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	assignment := NewCircuit(inputs)
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return Proof{}, fmt.Errorf("build witness: %w", err)
+	}
+
+	proof, err := native_groth16.Prove(ccs, pk, w)
+	if err != nil {
+		return Proof{}, fmt.Errorf("prove: %w", err)
+	}
+
+	return NewZKMGroth16Proof(&proof, inputs), nil
+}
+
+func loadPlonkProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_plonk.ProvingKey, error) {
+	pk := native_plonk.NewProvingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/plonk_pk.bin")
+	if err != nil {
+		return nil, fmt.Errorf("open plonk proving key (run BuildPlonk first): %w", err)
+	}
+	defer f.Close()
+	if _, err := pk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read plonk proving key: %w", err)
+	}
+	return pk, nil
+}
+
+func loadGroth16ProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_groth16.ProvingKey, error) {
+	pk := native_groth16.NewProvingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/groth16_pk.bin")
+	if err != nil {
+		return nil, fmt.Errorf("open groth16 proving key (run BuildGroth16 first): %w", err)
+	}
+	defer f.Close()
+	if _, err := pk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read groth16 proving key: %w", err)
+	}
+	return pk, nil
+}
+
+// constraintsManifest is the small JSON object ConstraintsReader carries:
+// the constraint count the caller expects the compiled circuit to report,
+// so a mismatch (e.g. a stale circuit binary) is caught before proving.
+type constraintsManifest struct {
+	Constraints int `json:"constraints"`
+}
+
+// RunConstraintTest is the env-var-free replacement for the old package
+// main TestMain: it compiles the witness's circuit and runs a single prove,
+// to sanity-check that the circuit and witness agree on constraint count
+// and satisfiability. Unlike the old TestMain, it honors opts.Backend:
+// TestGroth16Bn254 used to set the GROTH16 env var but TestMain never read
+// it back, so every "Groth16" test silently ran the PLONK path;
+// RunConstraintTest now actually runs the Groth16 setup/prove when
+// opts.Backend is "groth16".
+//
+// When opts.DataDir is empty it falls back to a dummy/unsafe setup, for
+// quick local iteration without a full ceremony; when set, it loads the
+// persisted proving key from that directory instead (the same
+// groth16_pk.bin/plonk_pk.bin convention ProveGroth16WithOptions and
+// ProvePlonkWithOptions already read), so TestPlonkBn254/TestGroth16Bn254
+// can exercise the real setup a production prove would use. groth16_pk.bin
+// may come from "zkm ceremony finalize"; plonk_pk.bin is the one BuildPlonk
+// writes.
+func RunConstraintTest(opts ProveOptions) error {
+	if opts.WitnessReader == nil {
+		return fmt.Errorf("RunConstraintTest: WitnessReader is required")
+	}
+
+	inputs, err := DecodeWitness(opts.WitnessReader)
+	if err != nil {
+		return fmt.Errorf("decode witness: %w", err)
+	}
+
+	circuit := NewCircuit(inputs)
+	builder := scs.NewBuilder
+	if opts.isGroth16() {
+		builder = r1cs.NewBuilder
+	}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &circuit)
+	if err != nil {
+		return fmt.Errorf("compile circuit: %w", err)
+	}
+	fmt.Println("[zkm] gnark verifier constraints:", ccs.GetNbConstraints())
+
+	if opts.ConstraintsReader != nil {
+		var manifest constraintsManifest
+		if err := json.NewDecoder(opts.ConstraintsReader).Decode(&manifest); err != nil {
+			return fmt.Errorf("decode constraints manifest: %w", err)
+		}
+		if manifest.Constraints != ccs.GetNbConstraints() {
+			return fmt.Errorf("constraint count mismatch: manifest says %d, circuit has %d", manifest.Constraints, ccs.GetNbConstraints())
+		}
+	}
+
+	assignment := NewCircuit(inputs)
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return fmt.Errorf("build witness: %w", err)
+	}
+	fmt.Println("[zkm] generate witness done")
+
+	if opts.isGroth16() {
+		pk, err := testGroth16ProvingKey(opts.DataDir, ccs)
+		if err != nil {
+			return err
+		}
+		if _, err := native_groth16.Prove(ccs, pk, w); err != nil {
+			return fmt.Errorf("prove: %w", err)
+		}
+		fmt.Println("[zkm] generate the proof done")
+		return nil
+	}
+
+	pk, err := testPlonkProvingKey(opts.DataDir, ccs)
+	if err != nil {
+		return err
+	}
+	if _, err := native_plonk.Prove(ccs, pk, w); err != nil {
+		return fmt.Errorf("prove: %w", err)
+	}
+	fmt.Println("[zkm] generate the proof done")
+	return nil
+}
+
+// testGroth16ProvingKey loads the ceremony-produced Groth16 proving key from
+// dataDir, or runs a dummy (unsound) setup if dataDir is empty.
+func testGroth16ProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_groth16.ProvingKey, error) {
+	if dataDir == "" {
+		pk, err := native_groth16.DummySetup(ccs)
+		if err != nil {
+			return nil, fmt.Errorf("dummy groth16 setup: %w", err)
+		}
+		fmt.Println("[zkm] run the dummy setup done")
+		return pk, nil
+	}
+	pk, err := loadGroth16ProvingKey(dataDir, ccs)
+	if err != nil {
+		return nil, fmt.Errorf("load ceremony groth16 proving key: %w", err)
+	}
+	fmt.Println("[zkm] loaded ceremony-produced groth16 proving key")
+	return pk, nil
+}
+
+// testPlonkProvingKey is the PLONK counterpart of testGroth16ProvingKey: it
+// loads the PLONK proving key BuildPlonk persisted to dataDir, or runs a
+// dummy (unsafekzg) setup if dataDir is empty.
+func testPlonkProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_plonk.ProvingKey, error) {
+	if dataDir == "" {
+		srs, srsLagrange, err := unsafekzg.NewSRS(ccs)
+		if err != nil {
+			return nil, fmt.Errorf("dummy srs: %w", err)
+		}
+		pk, _, err := native_plonk.Setup(ccs, srs, srsLagrange)
+		if err != nil {
+			return nil, fmt.Errorf("dummy plonk setup: %w", err)
+		}
+		fmt.Println("[zkm] run the dummy setup done")
+		return pk, nil
+	}
+	pk, err := loadPlonkProvingKey(dataDir, ccs)
+	if err != nil {
+		return nil, fmt.Errorf("load ceremony plonk proving key: %w", err)
+	}
+	fmt.Println("[zkm] loaded plonk proving key from data dir")
+	return pk, nil
+}
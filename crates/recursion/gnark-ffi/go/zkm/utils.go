@@ -3,15 +3,31 @@ package zkm
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
 
+	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/koalabear"
 	groth16 "github.com/consensys/gnark/backend/groth16"
 	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
 	plonk "github.com/consensys/gnark/backend/plonk"
 	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
 	"github.com/consensys/gnark/frontend"
-	"github.com/ProjectZKM/zkm-recursion-gnark/zkm/koalabear"
 )
 
+// writeGnarkFile persists a gnark-serialized object (a proving or verifying
+// key) to path, the Build-side counterpart of the load* helpers in
+// wrap.go/batch.go/batch_groth16.go that only ever open such a file.
+func writeGnarkFile(path string, src io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	defer f.Close()
+	_, err = src.WriteTo(f)
+	return err
+}
+
 func NewZKMPlonkBn254Proof(proof *plonk.Proof, witnessInput WitnessInput) Proof {
 	var buf bytes.Buffer
 	(*proof).WriteRawTo(&buf)
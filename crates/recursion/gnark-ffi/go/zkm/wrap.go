@@ -0,0 +1,265 @@
+package zkm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	native_plonk "github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/std/algebra/native/sw_bn254"
+	"github.com/consensys/gnark/std/math/bits"
+	"github.com/consensys/gnark/std/math/emulated"
+	stdplonk "github.com/consensys/gnark/std/recursion/plonk"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+// RecursiveWrapCircuit verifies a BN254 PLONK proof (as produced by
+// ProvePlonkBn254 / NewZKMPlonkBn254Proof) inside another BN254 circuit. It
+// is the building block for proof aggregation: the outer proof attests that
+// an inner zkMIPS proof verifies, and re-exposes the same two public
+// commitments (VkeyHash, CommittedValuesDigest) so the wrapping is
+// transparent to anything consuming the outer proof.
+type RecursiveWrapCircuit struct {
+	Proof        stdplonk.Proof[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine]
+	VerifyingKey stdplonk.VerifyingKey[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl] `gnark:"-"`
+	Witness      stdplonk.Witness[sw_bn254.ScalarField]
+
+	VkeyHash              frontend.Variable `gnark:",public"`
+	CommittedValuesDigest frontend.Variable `gnark:",public"`
+}
+
+// Define runs the in-circuit KZG-based PLONK verification of the wrapped
+// proof (linearized polynomial evaluation, folded openings, batched KZG
+// pairing check) and asserts that its two public inputs match the ones this
+// outer circuit re-commits.
+func (circuit *RecursiveWrapCircuit) Define(api frontend.API) error {
+	verifier, err := stdplonk.NewVerifier[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](api)
+	if err != nil {
+		return fmt.Errorf("new plonk verifier: %w", err)
+	}
+
+	if len(circuit.Witness.Public) != 2 {
+		return fmt.Errorf("expected 2 public inputs (vkeyHash, committedValuesDigest), got %d", len(circuit.Witness.Public))
+	}
+
+	if err := verifier.AssertProof(circuit.VerifyingKey, circuit.Proof, circuit.Witness, stdplonk.WithCompleteArithmetic()); err != nil {
+		return fmt.Errorf("assert inner plonk proof: %w", err)
+	}
+
+	field, err := emulated.NewField[sw_bn254.ScalarField](api)
+	if err != nil {
+		return fmt.Errorf("new emulated field: %w", err)
+	}
+	api.AssertIsEqual(circuit.VkeyHash, bits.FromBinary(api, field.ToBits(&circuit.Witness.Public[0])))
+	api.AssertIsEqual(circuit.CommittedValuesDigest, bits.FromBinary(api, field.ToBits(&circuit.Witness.Public[1])))
+
+	return nil
+}
+
+// ValueOfProof translates a raw plonk.Proof (as emitted by
+// NewZKMPlonkBn254Proof) into its in-circuit representation for use as a
+// RecursiveWrapCircuit witness.
+func ValueOfProof(proof native_plonk.Proof) (stdplonk.Proof[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine], error) {
+	return stdplonk.ValueOfProof[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine](proof)
+}
+
+// ValueOfVerifyingKey translates the inner circuit's plonk.VerifyingKey into
+// its in-circuit representation.
+func ValueOfVerifyingKey(vk native_plonk.VerifyingKey) (stdplonk.VerifyingKey[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl], error) {
+	return stdplonk.ValueOfVerifyingKey[sw_bn254.ScalarField, sw_bn254.G1Affine, sw_bn254.G2Affine, sw_bn254.GTEl](vk)
+}
+
+// ValueOfWitness translates the inner proof's public witness (its VkeyHash
+// and CommittedValuesDigest) into its in-circuit representation.
+func ValueOfWitness(w witness.Witness) (stdplonk.Witness[sw_bn254.ScalarField], error) {
+	return stdplonk.ValueOfWitness[sw_bn254.ScalarField](w)
+}
+
+// newWrapCompileTimeWitness returns a RecursiveWrapCircuit.Witness shaped
+// with its 2 public inputs (VkeyHash, CommittedValuesDigest) allocated but
+// unset, for use as the compile-time circuit passed to frontend.Compile: a
+// zero-valued Witness has Public == nil, which fails Define's own `len(...)
+// != 2` check during compilation, so every caller needs this before
+// Compile.
+func newWrapCompileTimeWitness() stdplonk.Witness[sw_bn254.ScalarField] {
+	return stdplonk.Witness[sw_bn254.ScalarField]{
+		Public: make([]emulated.Element[sw_bn254.ScalarField], 2),
+	}
+}
+
+// ProveWrapPlonkBn254 builds and proves a RecursiveWrapCircuit that re-proves
+// an existing PLONK proof and its (vkeyHash, committedValuesDigest) public
+// inputs, producing an outer BN254 proof carrying the same public
+// commitments as the wrapped proof.
+func ProveWrapPlonkBn254(dataDir string, innerVk native_plonk.VerifyingKey, innerProof native_plonk.Proof, innerWitness witness.Witness, vkeyHash string, committedValuesDigest string) Proof {
+	proof, err := ValueOfProof(innerProof)
+	if err != nil {
+		panic(err)
+	}
+	vk, err := ValueOfVerifyingKey(innerVk)
+	if err != nil {
+		panic(err)
+	}
+	pubWitness, err := ValueOfWitness(innerWitness)
+	if err != nil {
+		panic(err)
+	}
+
+	circuit := RecursiveWrapCircuit{VerifyingKey: vk, Witness: newWrapCompileTimeWitness()}
+	builder := scs.NewBuilder
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &circuit)
+	if err != nil {
+		panic(err)
+	}
+
+	pk, err := loadOrBuildWrapProvingKey(dataDir, ccs)
+	if err != nil {
+		panic(err)
+	}
+
+	assignment := RecursiveWrapCircuit{
+		Proof:                 proof,
+		Witness:               pubWitness,
+		VkeyHash:              vkeyHash,
+		CommittedValuesDigest: committedValuesDigest,
+	}
+
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		panic(err)
+	}
+
+	outerProof, err := native_plonk.Prove(ccs, pk, w)
+	if err != nil {
+		panic(err)
+	}
+
+	return NewZKMPlonkBn254Proof(&outerProof, WitnessInput{
+		VkeyHash:              vkeyHash,
+		CommittedValuesDigest: committedValuesDigest,
+	})
+}
+
+// VerifyWrapPlonkBn254 verifies an outer proof produced by
+// ProveWrapPlonkBn254 against the expected inner (vkeyHash,
+// committedValuesDigest) pair, the same calling convention as VerifyPlonk.
+func VerifyWrapPlonkBn254(dataDir string, proofStr string, vkeyHash string, committedValuesDigest string) error {
+	_, vk, err := loadWrapKeys(dataDir)
+	if err != nil {
+		return err
+	}
+
+	publicWitness, err := newWrapPublicWitness(vkeyHash, committedValuesDigest)
+	if err != nil {
+		return err
+	}
+
+	proofBytes, err := hex.DecodeString(proofStr)
+	if err != nil {
+		return fmt.Errorf("decode proof: %w", err)
+	}
+	proof := native_plonk.NewProof(ecc.BN254)
+	if _, err := proof.ReadFrom(bytes.NewReader(proofBytes)); err != nil {
+		return fmt.Errorf("read proof: %w", err)
+	}
+
+	return native_plonk.Verify(proof, vk, publicWitness)
+}
+
+// BuildWrapPlonk compiles RecursiveWrapCircuit against the base circuit's
+// verifying key at dataDir/plonk_vk.bin (the one constant every wrap proof
+// verifies against, since every inner proof comes from the same zkMIPS
+// verifier circuit) and persists wrap_plonk_pk.bin/wrap_plonk_vk.bin to
+// dataDir, the build path loadOrBuildWrapProvingKey never had despite its
+// name.
+func BuildWrapPlonk(dataDir string) error {
+	innerVk, err := loadInnerPlonkVerifyingKey(dataDir)
+	if err != nil {
+		return err
+	}
+	vk, err := ValueOfVerifyingKey(innerVk)
+	if err != nil {
+		return fmt.Errorf("convert inner verifying key: %w", err)
+	}
+
+	circuit := RecursiveWrapCircuit{VerifyingKey: vk, Witness: newWrapCompileTimeWitness()}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &circuit)
+	if err != nil {
+		return fmt.Errorf("compile wrap circuit: %w", err)
+	}
+
+	srs, srsLagrange, err := unsafekzg.NewSRS(ccs)
+	if err != nil {
+		return fmt.Errorf("srs: %w", err)
+	}
+	pk, wrapVk, err := native_plonk.Setup(ccs, srs, srsLagrange)
+	if err != nil {
+		return fmt.Errorf("setup: %w", err)
+	}
+
+	if err := writeGnarkFile(dataDir+"/wrap_plonk_pk.bin", pk); err != nil {
+		return err
+	}
+	return writeGnarkFile(dataDir+"/wrap_plonk_vk.bin", wrapVk)
+}
+
+// loadInnerPlonkVerifyingKey loads the base zkMIPS verifier circuit's
+// persisted plonk_vk.bin from dataDir, the inner verifying key every
+// RecursiveWrapCircuit/BatchCircuit compile-time constant is derived from.
+func loadInnerPlonkVerifyingKey(dataDir string) (native_plonk.VerifyingKey, error) {
+	vk := native_plonk.NewVerifyingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/plonk_vk.bin")
+	if err != nil {
+		return nil, fmt.Errorf("open inner verifying key (run BuildPlonk for the base circuit first): %w", err)
+	}
+	defer f.Close()
+	if _, err := vk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read inner verifying key: %w", err)
+	}
+	return vk, nil
+}
+
+func loadOrBuildWrapProvingKey(dataDir string, ccs constraint.ConstraintSystem) (native_plonk.ProvingKey, error) {
+	pkPath := dataDir + "/wrap_plonk_pk.bin"
+	pk := native_plonk.NewProvingKey(ecc.BN254)
+	f, err := os.Open(pkPath)
+	if err != nil {
+		return nil, fmt.Errorf("open wrap proving key (run BuildWrapPlonk first): %w", err)
+	}
+	defer f.Close()
+	if _, err := pk.ReadFrom(f); err != nil {
+		return nil, fmt.Errorf("read wrap proving key: %w", err)
+	}
+	return pk, nil
+}
+
+func loadWrapKeys(dataDir string) (native_plonk.ProvingKey, native_plonk.VerifyingKey, error) {
+	vk := native_plonk.NewVerifyingKey(ecc.BN254)
+	f, err := os.Open(dataDir + "/wrap_plonk_vk.bin")
+	if err != nil {
+		return nil, nil, fmt.Errorf("open wrap verifying key: %w", err)
+	}
+	defer f.Close()
+	if _, err := vk.ReadFrom(f); err != nil {
+		return nil, nil, fmt.Errorf("read wrap verifying key: %w", err)
+	}
+	return nil, vk, nil
+}
+
+func newWrapPublicWitness(vkeyHash string, committedValuesDigest string) (witness.Witness, error) {
+	assignment := RecursiveWrapCircuit{
+		VkeyHash:              vkeyHash,
+		CommittedValuesDigest: committedValuesDigest,
+	}
+	w, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return nil, fmt.Errorf("new public witness: %w", err)
+	}
+	return w, nil
+}